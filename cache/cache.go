@@ -1,13 +1,15 @@
 package cache
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
-// redigo 目前不支持 redis 集群
+// 支持单机、集群 (WithCluster)、哨兵 (WithSentinel) 模式
+// 通过 WithContext、WithTimeout 视图为命令设置截止时间，连接池的读写超时仍然生效
 // 命令的文字注释来自于 http://doc.redisfans.com，稍有修改
 
 var (
@@ -28,6 +30,22 @@ type Cache interface {
 	DO(cmd string, args ...interface{}) (interface{}, error)
 	Conn() Conn
 
+	// DOContext 执行命令，ctx 结束时立即返回，并关闭该命令使用的连接
+	DOContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error)
+
+	// WithContext 返回使用 ctx 执行命令的视图，所有命令方法均遵循 ctx 的截止时间与取消
+	// 视图与原对象共享连接池，不需要 Open，也不要 Close
+	WithContext(ctx context.Context) Cache
+
+	// WithTimeout 返回每条命令 (管道为每次 Exec) 超时时间为 timeout 的视图，见 WithContext
+	WithTimeout(timeout time.Duration) Cache
+
+	// Context 视图使用的 ctx，默认为 context.Background()
+	Context() context.Context
+
+	// Pipeline 创建管道，批量发送命令
+	Pipeline() Pipeline
+
 	Convert
 	Key
 	String
@@ -38,6 +56,7 @@ type Cache interface {
 	Bit
 	Script
 	PubSub
+	Transaction
 }
 
 // Conn ..
@@ -187,12 +206,15 @@ type PubSub interface {
 	Subscribe(onReady func() error, onMessage func(channel string, data []byte) error, num1, num2 int, channels ...string) error
 }
 
-// TODO Transaction
 // TODO Server
 
 type cache struct {
 	conf *config
-	pool *redis.Pool
+	exec executor
+
+	// ctx 与 timeout 用于 WithContext、WithTimeout 视图
+	ctx     context.Context
+	timeout time.Duration
 }
 
 // NewCache ..
@@ -217,14 +239,35 @@ func (c *cache) config() *config {
 }
 
 // Open ..
+//
+// 根据配置选择单机、集群 (WithCluster) 或者哨兵 (WithSentinel) 模式
 func (c *cache) Open() error {
-	return c.initRedis()
+	conf := c.conf
+
+	switch {
+	case len(conf.clusterAddrs) > 0:
+		exec, err := newCluster(conf)
+		if err != nil {
+			return err
+		}
+		c.exec = exec
+	case len(conf.sentinelAddrs) > 0:
+		exec, err := newSentinel(conf)
+		if err != nil {
+			return err
+		}
+		c.exec = exec
+	default:
+		c.exec = newSingle(conf)
+	}
+
+	return nil
 }
 
 // Close ..
 func (c *cache) Close() error {
-	if c.pool != nil {
-		return c.pool.Close()
+	if c.exec != nil {
+		return c.exec.close()
 	}
 
 	return nil
@@ -232,56 +275,52 @@ func (c *cache) Close() error {
 
 // DO ..
 func (c *cache) DO(cmd string, args ...interface{}) (interface{}, error) {
-	conn := c.pool.Get()
-	if conn == nil {
-		return nil, ErrInvalidConn
-	}
-
-	// 执行结束后，没有错误，没有关闭连接，没有超过 MaxIdle 情况下，activeConn 会放入 idle 队列
-	defer conn.Close()
+	ctx, cancel := c.context()
+	defer cancel()
 
-	if err := conn.Err(); err != nil {
-		return nil, err
-	}
+	return c.exec.do(ctx, cmd, args...)
+}
 
-	return conn.Do(cmd, args...)
+// DOContext ..
+func (c *cache) DOContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return c.exec.do(ctx, cmd, args...)
 }
 
-// Conn 获取 redigo Conn
-func (c *cache) Conn() Conn {
-	conn := c.pool.Get()
-	return conn
+// WithContext ..
+func (c *cache) WithContext(ctx context.Context) Cache {
+	view := *c
+	view.ctx = ctx
+	return &view
 }
 
-func (c *cache) initRedis() error {
-	conf := c.conf
+// WithTimeout ..
+func (c *cache) WithTimeout(timeout time.Duration) Cache {
+	view := *c
+	view.timeout = timeout
+	return &view
+}
 
-	pool := &redis.Pool{
-		MaxIdle:     conf.maxIdle,
-		IdleTimeout: conf.idleTimeout,
-		MaxActive:   conf.maxActive,
-		Wait:        conf.wait,
+// Context ..
+func (c *cache) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
 	}
+	return c.ctx
+}
 
-	// 创建新连接
-	pool.Dial = func() (redis.Conn, error) {
-		addr := fmt.Sprintf("%s:%d", conf.host, conf.port)
-		conn, err := redis.Dial(
-			"tcp",
-			addr,
-			redis.DialPassword(conf.password),
-			redis.DialDatabase(conf.db),
-			redis.DialReadTimeout(conf.dialReadTimeout),
-			redis.DialWriteTimeout(conf.dialWriteTimeout),
-			redis.DialConnectTimeout(conf.dialConnectTimeout),
-		)
-
-		return conn, err
+// context 执行一条命令使用的 ctx，设置了 timeout 时附加超时时间
+func (c *cache) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(c.Context(), c.timeout)
 	}
+	return c.Context(), func() {}
+}
 
-	c.pool = pool
-
-	return nil
+// Conn 获取 redigo Conn
+//
+// 集群模式下返回按照键路由的连接，见 clusterConn
+func (c *cache) Conn() Conn {
+	return c.exec.conn()
 }
 
 func (c *cache) Int(reply interface{}, err error) (int, error) {
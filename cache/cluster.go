@@ -0,0 +1,759 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// SlotCount 集群槽位数量
+const SlotCount = 16384
+
+const (
+	// maxRedirects 一条命令最多跟随 MOVED、ASK 的次数
+	maxRedirects = 5
+	// retryBackoff 集群返回 TRYAGAIN、CLUSTERDOWN 时的等待时间，每次重试递增
+	retryBackoff = 20 * time.Millisecond
+)
+
+var (
+	// ErrTooManyRedirects 超过最大重定向次数
+	ErrTooManyRedirects = errors.New("too many cluster redirects")
+	// ErrNoNode 无法从种子节点获取集群槽位分布
+	ErrNoNode = errors.New("no cluster node available")
+	// ErrNoPending 没有等待接收回复的命令
+	ErrNoPending = errors.New("no pending command")
+)
+
+// keylessCommands 不带键的命令，发送到任意节点
+var keylessCommands = map[string]bool{
+	"PING": true, "ECHO": true, "INFO": true, "TIME": true, "PUBLISH": true,
+	"SCRIPT": true, "CLUSTER": true, "DBSIZE": true, "RANDOMKEY": true,
+}
+
+// cluster 集群模式
+//
+// 维护 槽位 -> 节点 的映射，每个节点一个连接池，
+// 收到 MOVED 时更新映射并在后台刷新，收到 ASK 时先发送 ASKING 再在目标节点执行一次
+type cluster struct {
+	conf *config
+
+	mu    sync.RWMutex
+	slots []string
+	pools map[string]*redis.Pool
+
+	refreshing int32
+	closed     int32
+}
+
+func newCluster(conf *config) (*cluster, error) {
+	c := &cluster{
+		conf:  conf,
+		slots: make([]string, SlotCount),
+		pools: make(map[string]*redis.Pool),
+	}
+
+	if err := c.refresh(context.Background()); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Slot 计算键所在的槽位，CRC16 (XMODEM) 取模 16384
+// 键中包含 {tag} 时只计算 tag，用于将多个键放在同一个槽位
+func Slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key) % SlotCount)
+}
+
+func (c *cluster) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	switch strings.ToUpper(cmd) {
+	case "MGET":
+		return c.mget(ctx, args)
+	case "MSET":
+		return c.mset(ctx, args)
+	case "DEL", "UNLINK", "EXISTS", "TOUCH":
+		return c.sum(ctx, cmd, args)
+	}
+
+	key, ok := commandKey(cmd, args)
+	return c.doKey(ctx, key, ok, cmd, args...)
+}
+
+func (c *cluster) conn() redis.Conn {
+	return &clusterConn{c: c}
+}
+
+func (c *cluster) subConn() redis.Conn {
+	return c.pool(c.anyAddr()).Get()
+}
+
+// txConn 事务不能跨节点，keys 必须在同一个槽位，返回该槽位所在节点的连接
+func (c *cluster) txConn(ctx context.Context, keys []string) (redis.Conn, error) {
+	addr := c.anyAddr()
+	if len(keys) > 0 {
+		slot := Slot(keys[0])
+		for _, key := range keys[1:] {
+			if Slot(key) != slot {
+				return nil, ErrCrossSlot
+			}
+		}
+		addr = c.addrOf(slot)
+	}
+
+	return c.pool(addr).GetContext(ctx)
+}
+
+func (c *cluster) close() error {
+	atomic.StoreInt32(&c.closed, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for _, pool := range c.pools {
+		if e := pool.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// pipeline 按照节点将命令分组，每个节点使用一个连接发送管道
+// 跨槽位的批量命令，以及回复为重定向、CROSSSLOT 的命令，之后按照原有顺序通过 do 逐条执行
+func (c *cluster) pipeline(ctx context.Context, cmds []pipelineCmd, size int) {
+	retry := make(map[int]bool)
+
+	addrs := make([]string, 0)
+	byAddr := make(map[string][]pipelineCmd)
+	for idx, cmd := range cmds {
+		if crossSlot(cmd.cmd, cmd.args) {
+			retry[idx] = true
+			continue
+		}
+
+		addr := c.anyAddr()
+		if key, ok := commandKey(cmd.cmd, cmd.args); ok {
+			addr = c.addrOf(Slot(key))
+		}
+		if _, ok := byAddr[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+
+		idx, resolve := idx, cmd.resolve
+		cmd.resolve = func(reply interface{}, err error) {
+			if rerr, ok := err.(redis.Error); ok && needsRetry(rerr) {
+				retry[idx] = true
+				return
+			}
+			resolve(reply, err)
+		}
+		byAddr[addr] = append(byAddr[addr], cmd)
+	}
+
+	for _, addr := range addrs {
+		conn, err := c.pool(addr).GetContext(ctx)
+		if err != nil {
+			resolveAll(byAddr[addr], err)
+			continue
+		}
+		execPipeline(ctx, conn, byAddr[addr], size)
+		conn.Close()
+	}
+
+	for idx, cmd := range cmds {
+		if retry[idx] {
+			cmd.resolve(c.do(ctx, cmd.cmd, cmd.args...))
+		}
+	}
+}
+
+// crossSlot 是否为跨槽位的批量命令
+func crossSlot(cmd string, args []interface{}) bool {
+	step := 1
+	switch strings.ToUpper(cmd) {
+	case "MGET", "DEL", "UNLINK", "EXISTS", "TOUCH":
+	case "MSET":
+		step = 2
+	default:
+		return false
+	}
+	return len(args) > step && len(groupBySlot(args, step)) > 1
+}
+
+// needsRetry 管道中的命令被重定向或者跨槽位
+func needsRetry(err redis.Error) bool {
+	kind, _, _ := parseRedirect(err)
+	return kind != "" || strings.HasPrefix(string(err), "CROSSSLOT")
+}
+
+// doKey 在 key 所在的节点执行命令，处理重定向
+func (c *cluster) doKey(ctx context.Context, key string, hasKey bool, cmd string, args ...interface{}) (interface{}, error) {
+	addr := c.anyAddr()
+	if hasKey {
+		addr = c.addrOf(Slot(key))
+	}
+
+	asking := false
+	for i := 0; i < maxRedirects; i++ {
+		reply, err := c.doNode(ctx, addr, asking, cmd, args...)
+
+		rerr, ok := err.(redis.Error)
+		if !ok {
+			if isNetError(err) {
+				c.refreshAsync()
+			}
+			return reply, err
+		}
+
+		kind, slot, target := parseRedirect(rerr)
+		switch kind {
+		case "MOVED":
+			c.setSlot(slot, target)
+			c.refreshAsync()
+			addr, asking = target, false
+		case "ASK":
+			addr, asking = target, true
+		case "TRYAGAIN", "CLUSTERDOWN":
+			if err := sleepContext(ctx, retryBackoff*time.Duration(i+1)); err != nil {
+				return nil, err
+			}
+		default:
+			return reply, err
+		}
+	}
+
+	return nil, ErrTooManyRedirects
+}
+
+// doNode 在 addr 节点执行命令，asking 为 true 时先发送 ASKING
+func (c *cluster) doNode(ctx context.Context, addr string, asking bool, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if asking {
+		if err := conn.Send("ASKING"); err != nil {
+			return nil, err
+		}
+	}
+
+	return doContext(ctx, conn, cmd, args...)
+}
+
+// mget 按照槽位拆分为多个 MGET，同一节点的 MGET 通过管道发送，结果顺序与 args 一致
+func (c *cluster) mget(ctx context.Context, args []interface{}) (interface{}, error) {
+	groups := groupBySlot(args, 1)
+	if len(groups) <= 1 {
+		key, ok := commandKey("MGET", args)
+		return c.doKey(ctx, key, ok, "MGET", args...)
+	}
+
+	replies, err := c.doGroups(ctx, "MGET", groups)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, len(args))
+	for i, g := range groups {
+		reply, err := redis.Values(replies[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != len(g.indexes) {
+			return nil, errors.New("invalid length")
+		}
+		for idx, pos := range g.indexes {
+			results[pos] = reply[idx]
+		}
+	}
+
+	return results, nil
+}
+
+// mset 按照槽位拆分为多个 MSET，同一节点的 MSET 通过管道发送，不同槽位之间不是原子操作
+func (c *cluster) mset(ctx context.Context, args []interface{}) (interface{}, error) {
+	groups := groupBySlot(args, 2)
+	if len(groups) <= 1 {
+		key, ok := commandKey("MSET", args)
+		return c.doKey(ctx, key, ok, "MSET", args...)
+	}
+
+	if _, err := c.doGroups(ctx, "MSET", groups); err != nil {
+		return nil, err
+	}
+
+	return "OK", nil
+}
+
+// sum 按照槽位拆分 DEL、EXISTS 等命令，同一节点的命令通过管道发送，返回各个节点结果之和
+func (c *cluster) sum(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	groups := groupBySlot(args, 1)
+	if len(groups) <= 1 {
+		key, ok := commandKey(cmd, args)
+		return c.doKey(ctx, key, ok, cmd, args...)
+	}
+
+	replies, err := c.doGroups(ctx, cmd, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, reply := range replies {
+		n, err := redis.Int64(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+
+	return total, nil
+}
+
+// doGroups 每个槽位一条命令，通过 pipeline 按照节点分组发送，返回与 groups 顺序一致的回复
+func (c *cluster) doGroups(ctx context.Context, cmd string, groups []*slotGroup) ([]interface{}, error) {
+	replies := make([]interface{}, len(groups))
+	errs := make([]error, len(groups))
+
+	cmds := make([]pipelineCmd, len(groups))
+	for idx, g := range groups {
+		idx := idx
+		cmds[idx] = pipelineCmd{cmd: cmd, args: g.args, resolve: func(reply interface{}, err error) {
+			replies[idx], errs[idx] = reply, err
+		}}
+	}
+
+	size := c.conf.pipelineSize
+	if size <= 0 {
+		size = len(cmds)
+	}
+	c.pipeline(ctx, cmds, size)
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return replies, nil
+}
+
+// refresh 通过 CLUSTER SLOTS 获取槽位分布，依次尝试已知节点与种子节点
+func (c *cluster) refresh(ctx context.Context) error {
+	err := ErrNoNode
+	for _, addr := range c.knownAddrs() {
+		var slots []string
+		slots, err = c.fetchSlots(ctx, addr)
+		if err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		c.slots = slots
+		stale := c.removeStalePools()
+		c.mu.Unlock()
+
+		for _, pool := range stale {
+			pool.Close()
+		}
+		return nil
+	}
+
+	return err
+}
+
+// removeStalePools 移除不再负责任何槽位的节点的连接池，由调用者关闭，调用时需要持有 mu
+//
+// 正在使用的连接在归还时关闭，种子节点之后需要时重新创建
+func (c *cluster) removeStalePools() []*redis.Pool {
+	used := make(map[string]bool)
+	for _, addr := range c.slots {
+		if addr != "" {
+			used[addr] = true
+		}
+	}
+
+	var stale []*redis.Pool
+	for addr, pool := range c.pools {
+		if !used[addr] {
+			stale = append(stale, pool)
+			delete(c.pools, addr)
+		}
+	}
+	return stale
+}
+
+func (c *cluster) refreshAsync() {
+	if atomic.LoadInt32(&c.closed) == 1 || !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		_ = c.refresh(context.Background())
+	}()
+}
+
+// fetchSlots 从 addr 节点获取槽位分布
+//
+// CLUSTER SLOTS 回复: [[start, end, [host, port, id], 从节点...], ...]
+func (c *cluster) fetchSlots(ctx context.Context, addr string) ([]string, error) {
+	conn, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ranges, err := redis.Values(redis.DoContext(conn, ctx, "CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	slots := make([]string, SlotCount)
+	for _, r := range ranges {
+		fields, err := redis.Values(r, nil)
+		if err != nil || len(fields) < 3 {
+			return nil, errors.New("invalid cluster slots reply")
+		}
+
+		start, err1 := redis.Int(fields[0], nil)
+		end, err2 := redis.Int(fields[1], nil)
+		master, err3 := redis.Values(fields[2], nil)
+		if err1 != nil || err2 != nil || err3 != nil || len(master) < 2 || start < 0 || end >= SlotCount {
+			return nil, errors.New("invalid cluster slots reply")
+		}
+
+		nodeHost, _ := redis.String(master[0], nil)
+		nodePort, _ := redis.Int(master[1], nil)
+		if nodeHost == "" {
+			// 为空时表示与当前节点相同
+			nodeHost = host
+		}
+
+		nodeAddr := net.JoinHostPort(nodeHost, strconv.Itoa(nodePort))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = nodeAddr
+		}
+	}
+
+	return slots, nil
+}
+
+// knownAddrs 已知节点与种子节点
+func (c *cluster) knownAddrs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	addrs := make([]string, 0, len(c.pools)+len(c.conf.clusterAddrs))
+	for addr := range c.pools {
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+
+	for _, addr := range c.conf.clusterAddrs {
+		if !seen[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs
+}
+
+// addrOf 槽位所在的节点，未知时返回任意节点
+func (c *cluster) addrOf(slot int) string {
+	c.mu.RLock()
+	addr := c.slots[slot]
+	c.mu.RUnlock()
+
+	if addr == "" {
+		return c.anyAddr()
+	}
+	return addr
+}
+
+// anyAddr 任意一个节点
+func (c *cluster) anyAddr() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, addr := range c.slots {
+		if addr != "" {
+			return addr
+		}
+	}
+	return c.conf.clusterAddrs[0]
+}
+
+func (c *cluster) setSlot(slot int, addr string) {
+	if slot < 0 || slot >= SlotCount {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.slots[slot] = addr
+}
+
+// pool 获取 addr 节点的连接池，不存在时创建
+func (c *cluster) pool(addr string) *redis.Pool {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pool, ok := c.pools[addr]; ok {
+		return pool
+	}
+
+	conf := *c.conf
+	// 集群只有 0 号数据库
+	conf.db = 0
+	pool = newPool(&conf, func() (redis.Conn, error) {
+		return dial(&conf, addr)
+	})
+	c.pools[addr] = pool
+
+	return pool
+}
+
+// slotGroup 同一个槽位的参数
+type slotGroup struct {
+	args    []interface{}
+	indexes []int
+}
+
+// groupBySlot 将 键 (step 为 1) 或者 键值对 (step 为 2) 按照槽位分组，保持首次出现的顺序
+func groupBySlot(args []interface{}, step int) []*slotGroup {
+	groups := make([]*slotGroup, 0)
+	bySlot := make(map[int]*slotGroup)
+
+	for idx := 0; idx+step <= len(args); idx += step {
+		slot := Slot(keyString(args[idx]))
+
+		g, ok := bySlot[slot]
+		if !ok {
+			g = &slotGroup{}
+			bySlot[slot] = g
+			groups = append(groups, g)
+		}
+		g.args = append(g.args, args[idx:idx+step]...)
+		g.indexes = append(g.indexes, idx/step)
+	}
+
+	return groups
+}
+
+// commandKey 命令中用于路由的键
+func commandKey(cmd string, args []interface{}) (string, bool) {
+	cmd = strings.ToUpper(cmd)
+	if keylessCommands[cmd] || len(args) == 0 {
+		return "", false
+	}
+
+	switch cmd {
+	case "EVAL", "EVALSHA":
+		// EVAL script numkeys key [key ...] arg [arg ...]
+		if len(args) < 3 {
+			return "", false
+		}
+		if n, err := redis.Int(args[1], nil); err != nil || n == 0 {
+			return "", false
+		}
+		return keyString(args[2]), true
+	}
+
+	return keyString(args[0]), true
+}
+
+func keyString(v interface{}) string {
+	switch k := v.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	}
+	return fmt.Sprint(v)
+}
+
+// parseRedirect 解析集群错误，如 "MOVED 3999 127.0.0.1:6381"、"ASK 3999 127.0.0.1:6381"、"TRYAGAIN ..."
+func parseRedirect(err redis.Error) (kind string, slot int, addr string) {
+	fields := strings.Fields(string(err))
+	if len(fields) == 0 {
+		return "", 0, ""
+	}
+
+	kind = fields[0]
+	if (kind == "MOVED" || kind == "ASK") && len(fields) == 3 {
+		slot, _ = strconv.Atoi(fields[1])
+		return kind, slot, fields[2]
+	}
+	if kind == "TRYAGAIN" || kind == "CLUSTERDOWN" {
+		return kind, 0, ""
+	}
+
+	return "", 0, ""
+}
+
+// isNetError 网络错误，节点可能已下线
+// 调用者的 ctx 结束不是节点的问题，context.DeadlineExceeded 同样实现了 net.Error
+func isNetError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// crc16 CRC16-CCITT (XMODEM)
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// clusterConn 集群模式下 Conn 返回的连接，按照键路由每一条命令
+//
+// Send 的命令不会立即发送，在 Receive 或者 Do 时按照顺序逐条执行，
+// 不同节点之间没有原子性，不能用于 MULTI、WATCH 以及订阅，事务使用 Transaction
+type clusterConn struct {
+	c       *cluster
+	pending []pendingCommand
+	closed  bool
+}
+
+type pendingCommand struct {
+	cmd  string
+	args []interface{}
+}
+
+func (cc *clusterConn) Close() error {
+	cc.closed = true
+	cc.pending = nil
+	return nil
+}
+
+func (cc *clusterConn) Err() error {
+	if cc.closed {
+		return errors.New("redigo: closed")
+	}
+	return nil
+}
+
+func (cc *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return cc.DoContext(context.Background(), cmd, args...)
+}
+
+// DoContext cmd 为空时执行所有等待中的命令并返回所有回复，与 redigo 一致
+func (cc *clusterConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if err := cc.Err(); err != nil {
+		return nil, err
+	}
+
+	pending := cc.pending
+	cc.pending = nil
+
+	replies := make([]interface{}, 0, len(pending))
+	var pendingErr error
+	for _, p := range pending {
+		reply, err := cc.c.do(ctx, p.cmd, p.args...)
+		if rerr, ok := err.(redis.Error); ok {
+			reply = rerr
+			if pendingErr == nil {
+				pendingErr = rerr
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+
+	if cmd == "" {
+		return replies, nil
+	}
+
+	reply, err := cc.c.do(ctx, cmd, args...)
+	if err == nil && pendingErr != nil {
+		err = pendingErr
+	}
+	return reply, err
+}
+
+func (cc *clusterConn) Send(cmd string, args ...interface{}) error {
+	if err := cc.Err(); err != nil {
+		return err
+	}
+
+	cc.pending = append(cc.pending, pendingCommand{cmd: cmd, args: args})
+	return nil
+}
+
+func (cc *clusterConn) Flush() error {
+	return cc.Err()
+}
+
+func (cc *clusterConn) Receive() (interface{}, error) {
+	return cc.ReceiveContext(context.Background())
+}
+
+// ReceiveContext 执行第一条等待中的命令并返回回复
+func (cc *clusterConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	if err := cc.Err(); err != nil {
+		return nil, err
+	}
+	if len(cc.pending) == 0 {
+		return nil, ErrNoPending
+	}
+
+	p := cc.pending[0]
+	cc.pending = cc.pending[1:]
+	return cc.c.do(ctx, p.cmd, p.args...)
+}
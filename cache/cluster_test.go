@@ -0,0 +1,334 @@
+package cache_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+)
+
+func TestSlot(t *testing.T) {
+	if slot := zerocache.Slot("123456789"); slot != 12739 {
+		t.Errorf("test Slot failed, slot: %d", slot)
+	}
+	if slot := zerocache.Slot("foo"); slot != 12182 {
+		t.Errorf("test Slot failed, slot: %d", slot)
+	}
+	if zerocache.Slot("{user1000}.following") != zerocache.Slot("{user1000}.followers") {
+		t.Error("test Slot with hash tag failed")
+	}
+	if zerocache.Slot("{}foo") == zerocache.Slot("foo") {
+		t.Error("test Slot with empty hash tag failed")
+	}
+}
+
+// fakeCluster 两个节点，a 负责 [0, 8191]，b 负责 [8192, 16383]
+type fakeCluster struct {
+	a, b *cachetest.Server
+
+	mu sync.Mutex
+	// stale 为 true 时，a 负责所有槽位，模拟 b 下线或者客户端的槽位分布过期
+	stale bool
+	// migrating 正在从 b 迁移到 a 的槽位
+	migrating int
+}
+
+func newFakeCluster(t *testing.T) *fakeCluster {
+	fc := &fakeCluster{a: cachetest.NewServer(t), b: cachetest.NewServer(t), migrating: -1}
+	fc.a.SetHandler(fc.handler(fc.a))
+	fc.b.SetHandler(fc.handler(fc.b))
+	return fc
+}
+
+func (fc *fakeCluster) owner(slot int) *cachetest.Server {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if slot < 8192 || fc.stale {
+		return fc.a
+	}
+	return fc.b
+}
+
+func (fc *fakeCluster) slots() []interface{} {
+	node := func(s *cachetest.Server) []interface{} {
+		return []interface{}{s.Host(), int64(s.Port()), "id"}
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.stale {
+		return []interface{}{[]interface{}{int64(0), int64(16383), node(fc.a)}}
+	}
+	return []interface{}{
+		[]interface{}{int64(0), int64(8191), node(fc.a)},
+		[]interface{}{int64(8192), int64(16383), node(fc.b)},
+	}
+}
+
+func (fc *fakeCluster) handler(self *cachetest.Server) func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+	return func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+		switch cmd {
+		case "CLUSTER":
+			return fc.slots(), true
+		case "GET", "SET", "MGET", "MSET", "DEL", "EXISTS", "INCR":
+		default:
+			return nil, false
+		}
+
+		keys := args
+		if cmd == "SET" || cmd == "INCR" || cmd == "GET" {
+			keys = args[:1]
+		} else if cmd == "MSET" {
+			keys = nil
+			for i := 0; i < len(args); i += 2 {
+				keys = append(keys, args[i])
+			}
+		}
+
+		slot := zerocache.Slot(keys[0])
+		for _, key := range keys[1:] {
+			if zerocache.Slot(key) != slot {
+				return cachetest.Error("CROSSSLOT Keys in request don't hash to the same slot"), true
+			}
+		}
+
+		fc.mu.Lock()
+		migrating := fc.migrating == slot
+		fc.mu.Unlock()
+
+		owner := fc.owner(slot)
+		if migrating {
+			if self == fc.b {
+				if _, ok := self.Get(keys[0]); !ok {
+					return cachetest.Error(fmt.Sprintf("ASK %d %s", slot, fc.a.Addr())), true
+				}
+				return nil, false
+			}
+			if conn.Asking {
+				return nil, false
+			}
+		}
+
+		if owner != self {
+			return cachetest.Error(fmt.Sprintf("MOVED %d %s", slot, owner.Addr())), true
+		}
+		return nil, false
+	}
+}
+
+// keyIn 找到一个属于 s 的键
+func (fc *fakeCluster) keyIn(s *cachetest.Server, prefix string) string {
+	for i := 0; ; i++ {
+		key := prefix + strconv.Itoa(i)
+		if fc.owner(zerocache.Slot(key)) == s {
+			return key
+		}
+	}
+}
+
+func TestClusterRedirect(t *testing.T) {
+	fc := newFakeCluster(t)
+	fc.stale = true
+
+	c := zerocache.NewCache(zerocache.WithCluster(fc.a.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	// 集群重新分片，客户端的槽位分布已过期，a 返回 MOVED
+	fc.mu.Lock()
+	fc.stale = false
+	fc.mu.Unlock()
+
+	keyA, keyB := fc.keyIn(fc.a, "a"), fc.keyIn(fc.b, "b")
+	if err := c.Set(keyB, "b"); err != nil {
+		t.Fatalf("test Set with MOVED failed: %s", err.Error())
+	}
+	if v, ok := fc.b.Get(keyB); !ok || v != "b" {
+		t.Errorf("test Set with MOVED failed, value in b: %q", v)
+	}
+	if v, err := c.Get(keyB); err != nil || v != "b" {
+		t.Errorf("test Get failed, value: %q, err: %v", v, err)
+	}
+
+	// 跨槽位的批量命令按照槽位拆分
+	if err := c.Set(keyA, "a"); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+	values, err := c.MGet(keyB, "missing", keyA)
+	if err != nil {
+		t.Fatalf("test MGet failed: %s", err.Error())
+	}
+	if len(values) != 3 || values[0] != "b" || values[1] != "" || values[2] != "a" {
+		t.Errorf("test MGet failed, values: %v", values)
+	}
+	if n, err := c.Del(keyA, keyB); err != nil || n != 2 {
+		t.Errorf("test Del failed, n: %d, err: %v", n, err)
+	}
+}
+
+func TestClusterPipelineByNode(t *testing.T) {
+	fc := newFakeCluster(t)
+
+	c := zerocache.NewCache(zerocache.WithCluster(fc.a.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	// a 中不同槽位的键
+	keys := []interface{}{}
+	seen := map[int]bool{}
+	for i := 0; len(keys) < 4; i++ {
+		key := fc.keyIn(fc.a, "k"+strconv.Itoa(i)+"-")
+		if slot := zerocache.Slot(key); !seen[slot] {
+			seen[slot] = true
+			keys = append(keys, key)
+			fc.a.Set(key, key)
+		}
+	}
+
+	n := fc.a.RoundTrips()
+	values, err := c.MGet(keys...)
+	if err != nil || len(values) != 4 || values[3] != keys[3] {
+		t.Errorf("test MGet failed, values: %v, err: %v", values, err)
+	}
+	if deleted, err := c.Del(keys...); err != nil || deleted != 4 {
+		t.Errorf("test Del failed, n: %d, err: %v", deleted, err)
+	}
+	// 同一节点的命令通过一次往返发送
+	if trips := fc.a.RoundTrips() - n; trips != 2 {
+		t.Errorf("test pipeline by node failed, round trips: %d", trips)
+	}
+}
+
+func TestClusterClosesStalePools(t *testing.T) {
+	fc := newFakeCluster(t)
+
+	c := zerocache.NewCache(zerocache.WithCluster(fc.a.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	keyB := fc.keyIn(fc.b, "b")
+	if err := c.Set(keyB, "b"); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+	if n := fc.b.Clients(); n == 0 {
+		t.Fatal("test Set failed, no connection to b")
+	}
+
+	// b 下线，槽位全部迁移到 a，刷新后关闭 b 的连接池
+	fc.mu.Lock()
+	fc.stale = true
+	fc.mu.Unlock()
+	fc.a.Set(keyB, "a")
+
+	if v, err := c.Get(keyB); err != nil || v != "a" {
+		t.Errorf("test Get with MOVED failed, value: %q, err: %v", v, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fc.b.Clients() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := fc.b.Clients(); n != 0 {
+		t.Errorf("test stale pool failed, connections to b: %d", n)
+	}
+}
+
+func TestClusterAsk(t *testing.T) {
+	fc := newFakeCluster(t)
+
+	c := zerocache.NewCache(zerocache.WithCluster(fc.b.Addr(), fc.a.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	// 槽位正在从 b 迁移到 a，数据已经迁移到 a
+	key := fc.keyIn(fc.b, "k")
+	fc.mu.Lock()
+	fc.migrating = zerocache.Slot(key)
+	fc.mu.Unlock()
+	fc.a.Set(key, "moved")
+
+	if v, err := c.Get(key); err != nil || v != "moved" {
+		t.Errorf("test Get with ASK failed, value: %q, err: %v", v, err)
+	}
+}
+
+func TestClusterConn(t *testing.T) {
+	fc := newFakeCluster(t)
+
+	c := zerocache.NewCache(zerocache.WithCluster(fc.a.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	keyA, keyB := fc.keyIn(fc.a, "a"), fc.keyIn(fc.b, "b")
+
+	conn := c.Conn()
+	defer conn.Close()
+
+	conn.Send("SET", keyA, "1")
+	conn.Send("SET", keyB, "2")
+	replies, err := redis.Values(conn.Do(""))
+	if err != nil || len(replies) != 2 {
+		t.Fatalf("test pipeline failed, replies: %v, err: %v", replies, err)
+	}
+
+	if v, _ := fc.a.Get(keyA); v != "1" {
+		t.Errorf("test pipeline failed, value in a: %q", v)
+	}
+	if v, _ := fc.b.Get(keyB); v != "2" {
+		t.Errorf("test pipeline failed, value in b: %q", v)
+	}
+}
+
+func TestClusterPipeline(t *testing.T) {
+	fc := newFakeCluster(t)
+	fc.stale = true
+
+	c := zerocache.NewCache(zerocache.WithCluster(fc.a.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	fc.mu.Lock()
+	fc.stale = false
+	fc.mu.Unlock()
+
+	// keyB 收到 MOVED 后重新执行，跨槽位的 MGET 拆分执行
+	keyA, keyB := fc.keyIn(fc.a, "a"), fc.keyIn(fc.b, "b")
+	p := c.Pipeline()
+	setA := p.Set(keyA, "1")
+	setB := p.Set(keyB, "2")
+	mget := p.Do("MGET", keyA, keyB)
+	if err := p.Exec(); err != nil {
+		t.Fatalf("test Exec failed: %s", err.Error())
+	}
+
+	if setA.Err() != nil || setB.Err() != nil {
+		t.Errorf("test Set failed, errs: %v, %v", setA.Err(), setB.Err())
+	}
+	values, err := redis.Strings(mget.Result())
+	if err != nil || len(values) != 2 || values[0] != "1" || values[1] != "2" {
+		t.Errorf("test MGet failed, values: %v, err: %v", values, err)
+	}
+	if v, _ := fc.b.Get(keyB); v != "2" {
+		t.Errorf("test Set with MOVED failed, value in b: %q", v)
+	}
+}
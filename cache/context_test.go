@@ -0,0 +1,58 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+)
+
+func TestContext(t *testing.T) {
+	s, c := openServer(t)
+	s.Set("key", "value")
+
+	// GET slow 超过 ctx 的截止时间
+	s.SetHandler(func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+		if cmd == "GET" && args[0] == "slow" {
+			time.Sleep(300 * time.Millisecond)
+			return "slow", true
+		}
+		return nil, false
+	})
+
+	start := time.Now()
+	if _, err := c.WithTimeout(20 * time.Millisecond).Get("slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("test WithTimeout failed, err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("test WithTimeout failed, elapsed: %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.WithContext(ctx).Get("key"); !errors.Is(err, context.Canceled) {
+		t.Errorf("test WithContext with canceled ctx failed, err: %v", err)
+	}
+	if _, err := c.DOContext(ctx, "GET", "key"); !errors.Is(err, context.Canceled) {
+		t.Errorf("test DOContext with canceled ctx failed, err: %v", err)
+	}
+
+	// 视图不影响原对象
+	if c.Context() != context.Background() {
+		t.Error("test Context failed")
+	}
+	if v, err := c.Get("key"); err != nil || v != "value" {
+		t.Errorf("test Get failed, value: %q, err: %v", v, err)
+	}
+
+	p := c.WithTimeout(20 * time.Millisecond).Pipeline()
+	slow := p.Get("slow")
+	if err := p.Exec(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("test pipeline with timeout failed, err: %v", err)
+	}
+	if !errors.Is(slow.Err(), context.DeadlineExceeded) {
+		t.Errorf("test pipeline with timeout failed, err: %v", slow.Err())
+	}
+}
@@ -63,6 +63,10 @@ func main() {
 		log.Errorf("testScript failed: %s", err.Error())
 	}
 
+	if err := testPipeline(c); err != nil {
+		log.Errorf("testPipeline failed: %s", err.Error())
+	}
+
 	if err := testPubSub(c); err != nil {
 		log.Errorf("testPubSub failed: %s", err.Error())
 	}
@@ -432,6 +436,30 @@ func testScript(c zerocache.Cache) error {
 	return nil
 }
 
+func testPipeline(c zerocache.Cache) error {
+	key := "key:pipeline:" + zerotime.Date(zerotime.YMDHMS3)
+
+	p := c.Pipeline()
+	p.HSet(key, "field1", "value1")
+	p.HSet(key, "field2", "value2")
+	p.Expire(key, "120")
+	get := p.HGet(key, "field2")
+
+	if err := p.Exec(); err != nil {
+		return err
+	}
+
+	v, err := get.Result()
+	if err != nil {
+		return err
+	}
+	if v != "value2" {
+		return errors.New("testPipeline error 1")
+	}
+
+	return nil
+}
+
 func testPubSub(c zerocache.Cache) error {
 	quit := make(chan struct{}, 1)
 
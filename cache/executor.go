@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// executor 执行命令，单机、集群、哨兵模式各自实现
+type executor interface {
+	// do 执行一条命令
+	do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error)
+
+	// conn 获取一个连接，使用后需要关闭
+	conn() redis.Conn
+
+	// subConn 获取用于订阅的连接
+	subConn() redis.Conn
+
+	// txConn 获取用于事务的连接，keys 为事务中监视的键
+	txConn(ctx context.Context, keys []string) (redis.Conn, error)
+
+	close() error
+}
+
+// single 单机模式，连接 host:port
+type single struct {
+	pool *redis.Pool
+}
+
+func newSingle(conf *config) *single {
+	addr := fmt.Sprintf("%s:%d", conf.host, conf.port)
+	return &single{pool: newPool(conf, func() (redis.Conn, error) {
+		return dial(conf, addr)
+	})}
+}
+
+func (s *single) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 执行结束后，没有错误，没有关闭连接，没有超过 MaxIdle 情况下，activeConn 会放入 idle 队列
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+
+	return doContext(ctx, conn, cmd, args...)
+}
+
+func (s *single) conn() redis.Conn {
+	return s.pool.Get()
+}
+
+func (s *single) subConn() redis.Conn {
+	return s.pool.Get()
+}
+
+func (s *single) txConn(ctx context.Context, keys []string) (redis.Conn, error) {
+	return s.pool.GetContext(ctx)
+}
+
+func (s *single) close() error {
+	return s.pool.Close()
+}
+
+// doContext 在 conn 上执行命令
+// redigo 将 ctx 的截止时间作为读取超时，超时返回网络错误，此时改为返回 ctx 的错误
+func doContext(ctx context.Context, conn redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoContext(conn, ctx, cmd, args...)
+	if _, ok := err.(redis.Error); err != nil && !ok {
+		err = contextError(ctx, err)
+	}
+	return reply, err
+}
+
+// contextError 读取超时是由于 ctx 到期时，返回 ctx 的错误
+// 读取超时可能略早于 ctx 的计时器触发，所以同时比较截止时间
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// newPool 创建连接池
+func newPool(conf *config, dial func() (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     conf.maxIdle,
+		IdleTimeout: conf.idleTimeout,
+		MaxActive:   conf.maxActive,
+		Wait:        conf.wait,
+		Dial:        dial,
+	}
+}
+
+// dial 创建与 addr 的新连接
+func dial(conf *config, addr string) (redis.Conn, error) {
+	return redis.Dial(
+		"tcp",
+		addr,
+		redis.DialPassword(conf.password),
+		redis.DialDatabase(conf.db),
+		redis.DialReadTimeout(conf.dialReadTimeout),
+		redis.DialWriteTimeout(conf.dialWriteTimeout),
+		redis.DialConnectTimeout(conf.dialConnectTimeout),
+	)
+}
@@ -26,6 +26,17 @@ type config struct {
 	dialWriteTimeout time.Duration
 	// 连接 redis 服务器时的超时时间
 	dialConnectTimeout time.Duration
+
+	// clusterAddrs 集群模式下的种子节点，如 127.0.0.1:7000
+	clusterAddrs []string
+	// sentinelAddrs 哨兵地址，sentinelMaster 主节点名称
+	sentinelAddrs  []string
+	sentinelMaster string
+
+	// pipelineSize 管道每批发送的命令数量，0 表示不分批
+	pipelineSize int
+	// txRetries 事务因监视的键被修改而失败时的重试次数
+	txRetries int
 }
 
 func defaultConfig() *config {
@@ -40,6 +51,8 @@ func defaultConfig() *config {
 		dialReadTimeout:    time.Duration(500) * time.Millisecond,
 		dialWriteTimeout:   time.Duration(500) * time.Millisecond,
 		dialConnectTimeout: time.Duration(500) * time.Millisecond,
+		pipelineSize:       1000,
+		txRetries:          3,
 	}
 }
 
@@ -122,3 +135,34 @@ func WithDialConnectTimeout(timeout time.Duration) Option {
 		c.config().dialConnectTimeout = timeout
 	}
 }
+
+// WithPipelineSize 管道每批发送的命令数量，默认 1000，0 表示不分批
+func WithPipelineSize(size int) Option {
+	return func(c Cache) {
+		c.config().pipelineSize = size
+	}
+}
+
+// WithTxRetries 事务因监视的键被修改而失败时的重试次数，默认 3
+func WithTxRetries(retries int) Option {
+	return func(c Cache) {
+		c.config().txRetries = retries
+	}
+}
+
+// WithCluster 集群模式，addrs 为种子节点，如 127.0.0.1:7000，从中获取槽位分布
+// 集群模式下忽略 WithHost、WithPort、WithDB
+func WithCluster(addrs ...string) Option {
+	return func(c Cache) {
+		c.config().clusterAddrs = addrs
+	}
+}
+
+// WithSentinel 哨兵模式，通过哨兵 addrs 获取主节点 master 的地址，主节点切换后自动重新获取
+// 哨兵模式下忽略 WithHost、WithPort
+func WithSentinel(master string, addrs ...string) Option {
+	return func(c Cache) {
+		c.config().sentinelMaster = master
+		c.config().sentinelAddrs = addrs
+	}
+}
@@ -0,0 +1,339 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrNotExecuted 管道尚未执行，Future 还没有结果
+var ErrNotExecuted = errors.New("pipeline not executed")
+
+// Pipeline 管道，在同一个连接上批量发送命令 (Send/Flush/Receive)，减少网络往返
+//
+// 命令先加入队列，返回 Future，调用 Exec 后才能从 Future 中获取结果；
+// 命令数量超过 WithPipelineSize 时自动分批发送；
+// Pipeline 不是并发安全的，Exec 之后可以继续使用；
+// 由 Tx.Multi 创建的事务管道见 Tx
+type Pipeline interface {
+	// Do 加入任意命令
+	Do(cmd string, args ...interface{}) *Future[interface{}]
+
+	Get(key string) *Future[string]
+	Set(key string, value interface{}) *Future[string]
+	SetEx(key string, value interface{}, seconds string) *Future[string]
+	PSetEx(key string, value interface{}, milliseconds string) *Future[string]
+	MSet(v ...interface{}) *Future[string]
+	Incr(key string) *Future[int64]
+	Incrby(key string, increment int64) *Future[int64]
+
+	Del(key ...interface{}) *Future[int]
+	Expire(key, ex string) *Future[bool]
+	PExpire(key, ex string) *Future[bool]
+	ExpireAt(key string, t int) *Future[bool]
+
+	HGet(key, field string) *Future[string]
+	HSet(key, field string, value interface{}) *Future[int]
+	HMSet(v ...interface{}) *Future[string]
+	HDel(v ...interface{}) *Future[int]
+	HIncrby(key, field string, increment int) *Future[int]
+
+	LPush(v ...interface{}) *Future[int]
+	RPush(v ...interface{}) *Future[int]
+	SAdd(v ...interface{}) *Future[int]
+	SRem(v ...interface{}) *Future[int]
+	ZAdd(v ...interface{}) *Future[int]
+	ZRem(v ...interface{}) *Future[int]
+	ZIncrby(key, member string, increment int) *Future[int]
+
+	// Len 队列中的命令数量
+	Len() int
+
+	// Exec 发送队列中的所有命令，并将结果写入各自的 Future
+	// 返回第一个失败命令的错误；网络错误时，未发送的命令均以该错误结束
+	Exec() error
+}
+
+// Future 管道中一条命令的结果
+type Future[T any] struct {
+	reply   interface{}
+	err     error
+	done    bool
+	convert func(reply interface{}, err error) (T, error)
+}
+
+// Result 获取命令结果，Exec 之前调用返回 ErrNotExecuted
+func (f *Future[T]) Result() (T, error) {
+	if !f.done {
+		var zero T
+		return zero, ErrNotExecuted
+	}
+	return f.convert(f.reply, f.err)
+}
+
+// Err 命令执行的错误
+func (f *Future[T]) Err() error {
+	_, err := f.Result()
+	return err
+}
+
+func (f *Future[T]) resolve(reply interface{}, err error) {
+	f.reply, f.err, f.done = reply, err, true
+}
+
+// pipelineCmd 队列中的命令
+type pipelineCmd struct {
+	cmd     string
+	args    []interface{}
+	resolve func(reply interface{}, err error)
+}
+
+type pipeline struct {
+	c    *cache
+	cmds []pipelineCmd
+
+	// conn 不为空时为事务管道，在该连接上通过 MULTI/EXEC 执行，使用事务的 ctx
+	conn redis.Conn
+	ctx  context.Context
+}
+
+// Pipeline 创建管道
+func (c *cache) Pipeline() Pipeline {
+	return &pipeline{c: c}
+}
+
+// rawReply 不转换回复
+func rawReply(reply interface{}, err error) (interface{}, error) {
+	return reply, err
+}
+
+// queue 加入命令，返回对应的 Future
+func queue[T any](p *pipeline, convert func(interface{}, error) (T, error), cmd string, args ...interface{}) *Future[T] {
+	f := &Future[T]{convert: convert}
+	p.cmds = append(p.cmds, pipelineCmd{cmd: cmd, args: args, resolve: f.resolve})
+	return f
+}
+
+// failed 参数错误时不加入队列，直接返回带有错误的 Future
+func failed[T any](convert func(interface{}, error) (T, error), err error) *Future[T] {
+	return &Future[T]{err: err, done: true, convert: convert}
+}
+
+func (p *pipeline) Do(cmd string, args ...interface{}) *Future[interface{}] {
+	return queue(p, rawReply, cmd, args...)
+}
+
+func (p *pipeline) Get(key string) *Future[string] {
+	return queue(p, redis.String, "GET", key)
+}
+
+func (p *pipeline) Set(key string, value interface{}) *Future[string] {
+	return queue(p, redis.String, "SET", key, value)
+}
+
+func (p *pipeline) SetEx(key string, value interface{}, seconds string) *Future[string] {
+	return queue(p, redis.String, "SET", key, value, "EX", seconds)
+}
+
+func (p *pipeline) PSetEx(key string, value interface{}, milliseconds string) *Future[string] {
+	return queue(p, redis.String, "SET", key, value, "PX", milliseconds)
+}
+
+func (p *pipeline) MSet(v ...interface{}) *Future[string] {
+	if len(v) == 0 || len(v)%2 != 0 {
+		return failed(redis.String, ErrInvalidParamCount)
+	}
+	return queue(p, redis.String, "MSET", v...)
+}
+
+func (p *pipeline) Incr(key string) *Future[int64] {
+	return queue(p, redis.Int64, "INCR", key)
+}
+
+func (p *pipeline) Incrby(key string, increment int64) *Future[int64] {
+	return queue(p, redis.Int64, "INCRBY", key, increment)
+}
+
+func (p *pipeline) Del(key ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "DEL", key...)
+}
+
+func (p *pipeline) Expire(key, ex string) *Future[bool] {
+	return queue(p, redis.Bool, "EXPIRE", key, ex)
+}
+
+func (p *pipeline) PExpire(key, ex string) *Future[bool] {
+	return queue(p, redis.Bool, "PEXPIRE", key, ex)
+}
+
+func (p *pipeline) ExpireAt(key string, t int) *Future[bool] {
+	return queue(p, redis.Bool, "EXPIREAT", key, t)
+}
+
+func (p *pipeline) HGet(key, field string) *Future[string] {
+	return queue(p, redis.String, "HGET", key, field)
+}
+
+// HSet 返回新建域的数量
+func (p *pipeline) HSet(key, field string, value interface{}) *Future[int] {
+	return queue(p, redis.Int, "HSET", key, field, value)
+}
+
+func (p *pipeline) HMSet(v ...interface{}) *Future[string] {
+	if len(v) == 0 || len(v)%2 == 0 {
+		return failed(redis.String, ErrInvalidParamCount)
+	}
+	return queue(p, redis.String, "HMSET", v...)
+}
+
+func (p *pipeline) HDel(v ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "HDEL", v...)
+}
+
+func (p *pipeline) HIncrby(key, field string, increment int) *Future[int] {
+	return queue(p, redis.Int, "HINCRBY", key, field, increment)
+}
+
+func (p *pipeline) LPush(v ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "LPUSH", v...)
+}
+
+func (p *pipeline) RPush(v ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "RPUSH", v...)
+}
+
+func (p *pipeline) SAdd(v ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "SADD", v...)
+}
+
+func (p *pipeline) SRem(v ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "SREM", v...)
+}
+
+// ZAdd 第一个 v 是 key，ZADD key score member [[score member] ...]
+func (p *pipeline) ZAdd(v ...interface{}) *Future[int] {
+	if len(v) == 0 || len(v)%2 == 0 {
+		return failed(redis.Int, ErrInvalidParamCount)
+	}
+	return queue(p, redis.Int, "ZADD", v...)
+}
+
+func (p *pipeline) ZRem(v ...interface{}) *Future[int] {
+	return queue(p, redis.Int, "ZREM", v...)
+}
+
+func (p *pipeline) ZIncrby(key, member string, increment int) *Future[int] {
+	return queue(p, redis.Int, "ZINCRBY", key, increment, member)
+}
+
+func (p *pipeline) Len() int {
+	return len(p.cmds)
+}
+
+func (p *pipeline) Exec() error {
+	cmds := p.cmds
+	p.cmds = nil
+
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	// 记录第一个失败命令的错误
+	var first error
+	for i := range cmds {
+		resolve := cmds[i].resolve
+		cmds[i].resolve = func(reply interface{}, err error) {
+			if err != nil && first == nil {
+				first = err
+			}
+			resolve(reply, err)
+		}
+	}
+
+	if p.conn != nil {
+		execMulti(p.ctx, p.conn, cmds)
+		return first
+	}
+
+	ctx, cancel := p.c.context()
+	defer cancel()
+
+	size := p.c.conf.pipelineSize
+	if size <= 0 {
+		size = len(cmds)
+	}
+
+	if pl, ok := p.c.exec.(pipeliner); ok {
+		pl.pipeline(ctx, cmds, size)
+		return first
+	}
+
+	conn := p.c.exec.conn()
+	if conn == nil {
+		resolveAll(cmds, ErrInvalidConn)
+		return first
+	}
+	defer conn.Close()
+
+	execPipeline(ctx, conn, cmds, size)
+	return first
+}
+
+// pipeliner 自行实现管道的执行器，如集群模式需要按照节点分组
+type pipeliner interface {
+	pipeline(ctx context.Context, cmds []pipelineCmd, size int)
+}
+
+// execPipeline 在 conn 上按照每批 size 条发送命令，连接不可用时剩余的命令均以该错误结束
+// ctx 结束时连接被关闭，剩余的命令以 ctx 的错误结束
+func execPipeline(ctx context.Context, conn redis.Conn, cmds []pipelineCmd, size int) {
+	for start := 0; start < len(cmds); start += size {
+		end := start + size
+		if end > len(cmds) {
+			end = len(cmds)
+		}
+
+		if err := execChunk(ctx, conn, cmds[start:end]); err != nil {
+			resolveAll(cmds[end:], err)
+			return
+		}
+	}
+}
+
+// execChunk 发送一批命令并读取回复，返回网络等导致连接不可用的错误
+// 命令本身的错误 (redis.Error) 只写入对应的 Future
+func execChunk(ctx context.Context, conn redis.Conn, cmds []pipelineCmd) error {
+	err := conn.Err()
+	for i := 0; err == nil && i < len(cmds); i++ {
+		err = conn.Send(cmds[i].cmd, cmds[i].args...)
+	}
+	if err == nil {
+		err = conn.Flush()
+	}
+	if err != nil {
+		resolveAll(cmds, err)
+		return err
+	}
+
+	for i, cmd := range cmds {
+		reply, err := redis.ReceiveContext(conn, ctx)
+		if _, ok := err.(redis.Error); err != nil && !ok {
+			err = contextError(ctx, err)
+			// 已经收到的回复保留
+			resolveAll(cmds[i:], err)
+			return err
+		}
+
+		cmd.resolve(reply, err)
+	}
+
+	return nil
+}
+
+// resolveAll 以错误 err 结束尚未完成的命令
+func resolveAll(cmds []pipelineCmd, err error) {
+	for _, cmd := range cmds {
+		cmd.resolve(nil, err)
+	}
+}
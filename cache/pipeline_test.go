@@ -0,0 +1,65 @@
+package cache_test
+
+import (
+	"testing"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+)
+
+func TestPipeline(t *testing.T) {
+	s := cachetest.NewServer(t)
+
+	c := zerocache.NewCache(
+		zerocache.WithHost(s.Host()),
+		zerocache.WithPort(s.Port()),
+		zerocache.WithPipelineSize(2),
+	)
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	p := c.Pipeline()
+	set := p.Set("k1", "v1")
+	get := p.Get("k1")
+	incr := p.Incr("n")
+	missing := p.Get("missing")
+	bad := p.Do("BOGUS")
+
+	if _, err := get.Result(); err != zerocache.ErrNotExecuted {
+		t.Errorf("test Result before Exec failed, err: %v", err)
+	}
+	if n := p.Len(); n != 5 {
+		t.Errorf("test Len failed, len: %d", n)
+	}
+
+	// BOGUS 失败不影响其它命令
+	if err := p.Exec(); err == nil || err != bad.Err() {
+		t.Errorf("test Exec failed, err: %v", err)
+	}
+	if err := set.Err(); err != nil {
+		t.Errorf("test Set failed: %s", err.Error())
+	}
+	if v, err := get.Result(); err != nil || v != "v1" {
+		t.Errorf("test Get failed, value: %q, err: %v", v, err)
+	}
+	if n, err := incr.Result(); err != nil || n != 1 {
+		t.Errorf("test Incr failed, n: %d, err: %v", n, err)
+	}
+	if _, err := missing.Result(); err != zerocache.ErrNil {
+		t.Errorf("test Get missing failed, err: %v", err)
+	}
+
+	// 5 条命令，每批 2 条，共 3 次往返
+	if n := s.RoundTrips(); n != 3 {
+		t.Errorf("test pipeline chunk failed, round trips: %d", n)
+	}
+
+	if p.Len() != 0 {
+		t.Error("test Exec failed, queue not cleared")
+	}
+	if f := p.ZAdd("z", 1); f.Err() != zerocache.ErrInvalidParamCount {
+		t.Errorf("test ZAdd with invalid params failed, err: %v", f.Err())
+	}
+}
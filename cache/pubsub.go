@@ -23,7 +23,7 @@ func (c *cache) Subscribe(onReady func() error, onMessage func(channel string, d
 		return errors.New("onMessage cant be nil")
 	}
 
-	psc := redis.PubSubConn{Conn: c.pool.Get()}
+	psc := redis.PubSubConn{Conn: c.exec.subConn()}
 
 	if err := psc.Subscribe(redis.Args{}.AddFlat(channels)...); err != nil {
 		if num1 > 0 {
@@ -37,7 +37,8 @@ func (c *cache) Subscribe(onReady func() error, onMessage func(channel string, d
 
 	go func() {
 		for {
-			switch v := psc.Receive().(type) {
+			// 订阅连接可能长时间没有消息，不使用连接的读取超时，断线由健康检查发现
+			switch v := psc.ReceiveWithTimeout(0).(type) {
 			case error:
 				quit <- v
 				return
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrNoMaster 无法从哨兵获取主节点地址，或者获取到的节点不是主节点
+var ErrNoMaster = errors.New("no master available")
+
+// sentinel 哨兵模式
+//
+// 通过哨兵获取主节点地址，连接池只连接该主节点；
+// 命令返回 READONLY 或者网络错误时认为发生了主从切换，重新获取主节点并替换连接池
+type sentinel struct {
+	conf *config
+
+	mu   sync.Mutex
+	addr string
+	pool *redis.Pool
+}
+
+func newSentinel(conf *config) (*sentinel, error) {
+	s := &sentinel{conf: conf}
+	if _, err := s.switchMaster(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *sentinel) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	reply, sent, err := s.doMaster(ctx, cmd, args...)
+	if !s.failover(err) {
+		return reply, err
+	}
+
+	changed, serr := s.switchMaster(ctx)
+	if serr != nil {
+		return reply, err
+	}
+
+	// READONLY 表示命令被拒绝，命令未发送表示连接失败，这两种情况可以安全地重试
+	if changed && (!sent || isReadOnly(err)) {
+		reply, _, err = s.doMaster(ctx, cmd, args...)
+	}
+
+	return reply, err
+}
+
+// doMaster 在主节点执行命令，sent 表示命令是否已经发送
+func (s *sentinel) doMaster(ctx context.Context, cmd string, args ...interface{}) (reply interface{}, sent bool, err error) {
+	conn, err := s.current().GetContext(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	reply, err = doContext(ctx, conn, cmd, args...)
+	return reply, true, err
+}
+
+func (s *sentinel) conn() redis.Conn {
+	return s.current().Get()
+}
+
+func (s *sentinel) subConn() redis.Conn {
+	return s.current().Get()
+}
+
+func (s *sentinel) txConn(ctx context.Context, keys []string) (redis.Conn, error) {
+	return s.current().GetContext(ctx)
+}
+
+func (s *sentinel) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pool != nil {
+		return s.pool.Close()
+	}
+	return nil
+}
+
+func (s *sentinel) current() *redis.Pool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pool
+}
+
+// switchMaster 重新获取主节点，地址变化时替换连接池，旧连接池中的连接在归还时关闭
+func (s *sentinel) switchMaster(ctx context.Context) (bool, error) {
+	addr, err := s.discover(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if addr == s.addr && s.pool != nil {
+		return false, nil
+	}
+
+	old := s.pool
+	s.addr = addr
+	s.pool = newPool(s.conf, func() (redis.Conn, error) {
+		return dialMaster(s.conf, addr)
+	})
+	if old != nil {
+		old.Close()
+	}
+
+	return true, nil
+}
+
+// discover 依次询问哨兵，获取主节点地址
+func (s *sentinel) discover(ctx context.Context) (string, error) {
+	err := ErrNoMaster
+	for _, sentinelAddr := range s.conf.sentinelAddrs {
+		var addr string
+		addr, err = s.queryMaster(ctx, sentinelAddr)
+		if err == nil {
+			return addr, nil
+		}
+	}
+
+	return "", err
+}
+
+// queryMaster SENTINEL get-master-addr-by-name，回复 [host, port]，主节点未知时回复 nil
+func (s *sentinel) queryMaster(ctx context.Context, sentinelAddr string) (string, error) {
+	conn, err := redis.Dial(
+		"tcp",
+		sentinelAddr,
+		redis.DialReadTimeout(s.conf.dialReadTimeout),
+		redis.DialWriteTimeout(s.conf.dialWriteTimeout),
+		redis.DialConnectTimeout(s.conf.dialConnectTimeout),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	fields, err := redis.Strings(redis.DoContext(conn, ctx, "SENTINEL", "get-master-addr-by-name", s.conf.sentinelMaster))
+	if err == redis.ErrNil {
+		return "", ErrNoMaster
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(fields) != 2 {
+		return "", errors.New("invalid sentinel reply")
+	}
+
+	return net.JoinHostPort(fields[0], fields[1]), nil
+}
+
+// failover 是否可能发生了主从切换
+func (s *sentinel) failover(err error) bool {
+	if err == nil {
+		return false
+	}
+	return isReadOnly(err) || errors.Is(err, ErrNoMaster) || isNetError(err)
+}
+
+// dialMaster 连接 addr，并确认其角色为主节点
+func dialMaster(conf *config, addr string) (redis.Conn, error) {
+	conn, err := dial(conf, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := redis.Values(conn.Do("ROLE"))
+	if err != nil || len(role) == 0 {
+		conn.Close()
+		return nil, ErrNoMaster
+	}
+	if name, _ := redis.String(role[0], nil); name != "master" {
+		conn.Close()
+		return nil, ErrNoMaster
+	}
+
+	return conn, nil
+}
+
+// isReadOnly 写入了从节点
+func isReadOnly(err error) bool {
+	rerr, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(rerr), "READONLY")
+}
@@ -0,0 +1,65 @@
+package cache_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+)
+
+func TestSentinelFailover(t *testing.T) {
+	m1, m2 := cachetest.NewServer(t), cachetest.NewServer(t)
+
+	var mu sync.Mutex
+	master := m1
+
+	s := cachetest.NewServer(t)
+	s.SetHandler(func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+		if cmd != "SENTINEL" || len(args) != 2 || args[1] != "mymaster" {
+			return nil, false
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return []interface{}{master.Host(), strconv.Itoa(master.Port())}, true
+	})
+
+	c := zerocache.NewCache(zerocache.WithSentinel("mymaster", s.Addr()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	defer c.Close()
+
+	if err := c.Set("k", "1"); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+	if v, _ := m1.Get("k"); v != "1" {
+		t.Fatalf("test Set failed, value in m1: %q", v)
+	}
+
+	// 主从切换，m1 降级为从节点
+	m1.SetHandler(func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+		switch cmd {
+		case "ROLE":
+			return []interface{}{"slave", m2.Host(), int64(m2.Port())}, true
+		case "SET":
+			return cachetest.Error("READONLY You can't write against a read only replica."), true
+		}
+		return nil, false
+	})
+	mu.Lock()
+	master = m2
+	mu.Unlock()
+
+	if err := c.Set("k", "2"); err != nil {
+		t.Fatalf("test Set after failover failed: %s", err.Error())
+	}
+	if v, _ := m2.Get("k"); v != "2" {
+		t.Errorf("test Set after failover failed, value in m2: %q", v)
+	}
+	if v, err := c.Get("k"); err != nil || v != "2" {
+		t.Errorf("test Get after failover failed, value: %q, err: %v", v, err)
+	}
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	// ErrTxFailed 被监视的键在 EXEC 之前被修改，事务没有执行
+	ErrTxFailed = errors.New("transaction failed, watched keys changed")
+	// ErrCrossSlot 集群模式下事务中的键不在同一个槽位
+	ErrCrossSlot = errors.New("keys in transaction don't hash to the same slot")
+)
+
+// Tx 事务，所有命令都在同一个连接上执行
+type Tx interface {
+	// Do 立即执行命令，用于在 MULTI 之前读取被监视的键
+	Do(cmd string, args ...interface{}) (interface{}, error)
+
+	// Watch 追加监视的键，需要在 Multi 之前调用
+	Watch(keys ...string) error
+
+	// Unwatch 取消监视所有键
+	Unwatch() error
+
+	// Multi 返回事务管道，其中的命令在 Exec 时通过 MULTI/EXEC 原子执行，不分批
+	// 被监视的键被修改时 Exec 返回 ErrTxFailed
+	Multi() Pipeline
+}
+
+// Transaction 乐观锁事务
+//
+// 使用示例，check-and-set:
+//
+//	err := c.Transaction(func(tx Tx) error {
+//		n, err := redis.Int(tx.Do("GET", key))
+//		if err != nil && err != ErrNil {
+//			return err
+//		}
+//		p := tx.Multi()
+//		p.Set(key, n+1)
+//		return p.Exec()
+//	}, key)
+type Transaction interface {
+	// Transaction 监视 keys 后执行 fn，fn 返回的错误为 ErrTxFailed (errors.Is) 时重新执行，
+	// 最多重试 WithTxRetries 次，之后返回最后一次的错误；fn 返回后连接归还连接池，未完成的事务与监视会被取消
+	// 集群模式下 keys 必须在同一个槽位，事务在该槽位所在的节点执行
+	// WithTimeout 视图的超时时间作用于每一次执行 fn
+	Transaction(fn func(tx Tx) error, keys ...string) error
+}
+
+type tx struct {
+	c    *cache
+	ctx  context.Context
+	conn redis.Conn
+}
+
+// Transaction 见 Transaction 接口
+func (c *cache) Transaction(fn func(tx Tx) error, keys ...string) error {
+	var err error
+	for i := 0; i <= c.conf.txRetries; i++ {
+		if err = c.transaction(fn, keys); !errors.Is(err, ErrTxFailed) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (c *cache) transaction(fn func(tx Tx) error, keys []string) error {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	conn, err := c.exec.txConn(ctx, keys)
+	if err != nil {
+		return err
+	}
+	// 连接池在归还连接时发送 DISCARD 或者 UNWATCH
+	defer conn.Close()
+
+	t := &tx{c: c, ctx: ctx, conn: conn}
+	if len(keys) > 0 {
+		if err := t.Watch(keys...); err != nil {
+			return err
+		}
+	}
+
+	return fn(t)
+}
+
+func (t *tx) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return doContext(t.ctx, t.conn, cmd, args...)
+}
+
+func (t *tx) Watch(keys ...string) error {
+	_, err := doContext(t.ctx, t.conn, "WATCH", redis.Args{}.AddFlat(keys)...)
+	return err
+}
+
+func (t *tx) Unwatch() error {
+	_, err := doContext(t.ctx, t.conn, "UNWATCH")
+	return err
+}
+
+func (t *tx) Multi() Pipeline {
+	return &pipeline{c: t.c, ctx: t.ctx, conn: t.conn}
+}
+
+// execMulti 一次往返发送 MULTI、队列中的命令和 EXEC
+func execMulti(ctx context.Context, conn redis.Conn, cmds []pipelineCmd) {
+	err := conn.Send("MULTI")
+	for i := 0; err == nil && i < len(cmds); i++ {
+		err = conn.Send(cmds[i].cmd, cmds[i].args...)
+	}
+	if err == nil {
+		err = conn.Send("EXEC")
+	}
+	if err == nil {
+		err = conn.Flush()
+	}
+	if err != nil {
+		resolveAll(cmds, err)
+		return
+	}
+
+	// MULTI 和每条命令回复 OK、QUEUED，命令错误时 EXEC 回复 EXECABORT
+	queueErrs := make([]error, len(cmds))
+	for i := -1; i < len(cmds); i++ {
+		_, err := redis.ReceiveContext(conn, ctx)
+		if _, ok := err.(redis.Error); err != nil && !ok {
+			resolveAll(cmds, contextError(ctx, err))
+			return
+		}
+		if i >= 0 {
+			queueErrs[i] = err
+		}
+	}
+
+	replies, err := redis.Values(redis.ReceiveContext(conn, ctx))
+	if err == redis.ErrNil {
+		resolveAll(cmds, ErrTxFailed)
+		return
+	}
+	if _, ok := err.(redis.Error); err != nil && !ok {
+		resolveAll(cmds, contextError(ctx, err))
+		return
+	}
+	if err != nil {
+		for i, cmd := range cmds {
+			if queueErrs[i] != nil {
+				cmd.resolve(nil, queueErrs[i])
+			} else {
+				cmd.resolve(nil, err)
+			}
+		}
+		return
+	}
+
+	for i, cmd := range cmds {
+		if i >= len(replies) {
+			cmd.resolve(nil, ErrInvalidParamCount)
+			continue
+		}
+		if rerr, ok := replies[i].(redis.Error); ok {
+			cmd.resolve(nil, rerr)
+			continue
+		}
+		cmd.resolve(replies[i], nil)
+	}
+}
@@ -0,0 +1,163 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+)
+
+func openServer(t *testing.T, opts ...zerocache.Option) (*cachetest.Server, zerocache.Cache) {
+	s := cachetest.NewServer(t)
+
+	opts = append([]zerocache.Option{zerocache.WithHost(s.Host()), zerocache.WithPort(s.Port())}, opts...)
+	c := zerocache.NewCache(opts...)
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return s, c
+}
+
+func TestTransaction(t *testing.T) {
+	s, c := openServer(t)
+	s.Set("counter", "10")
+
+	attempts := 0
+	err := c.Transaction(func(tx zerocache.Tx) error {
+		attempts++
+
+		n, err := c.Int(tx.Do("GET", "counter"))
+		if err != nil {
+			return err
+		}
+
+		// 第一次执行时，其它客户端修改了被监视的键
+		if attempts == 1 {
+			if err := c.Set("counter", "20"); err != nil {
+				return err
+			}
+		}
+
+		p := tx.Multi()
+		set := p.Set("counter", n+1)
+		if err := p.Exec(); err != nil {
+			return err
+		}
+		return set.Err()
+	}, "counter")
+	if err != nil {
+		t.Fatalf("test Transaction failed: %s", err.Error())
+	}
+
+	if attempts != 2 {
+		t.Errorf("test Transaction retry failed, attempts: %d", attempts)
+	}
+	if v, _ := s.Get("counter"); v != "21" {
+		t.Errorf("test Transaction failed, counter: %q", v)
+	}
+}
+
+func TestTransactionRetriesExhausted(t *testing.T) {
+	s, c := openServer(t, zerocache.WithTxRetries(1))
+
+	attempts := 0
+	err := c.Transaction(func(tx zerocache.Tx) error {
+		attempts++
+		s.Set("key", strconv.Itoa(attempts))
+
+		p := tx.Multi()
+		p.Set("key", "tx")
+		return p.Exec()
+	}, "key")
+
+	if err != zerocache.ErrTxFailed {
+		t.Errorf("test Transaction failed, err: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("test Transaction retry failed, attempts: %d", attempts)
+	}
+
+	// fn 包装后的 ErrTxFailed 同样重试
+	attempts = 0
+	err = c.Transaction(func(tx zerocache.Tx) error {
+		attempts++
+		s.Set("key", strconv.Itoa(attempts))
+
+		p := tx.Multi()
+		p.Set("key", "tx")
+		return fmt.Errorf("set key: %w", p.Exec())
+	}, "key")
+
+	if !errors.Is(err, zerocache.ErrTxFailed) {
+		t.Errorf("test Transaction with wrapped error failed, err: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("test Transaction retry failed, attempts: %d", attempts)
+	}
+	if v, _ := s.Get("key"); v != "2" {
+		t.Errorf("test Transaction failed, key: %q", v)
+	}
+}
+
+func TestTransactionAbort(t *testing.T) {
+	s, c := openServer(t)
+
+	var set *zerocache.Future[string]
+	err := c.Transaction(func(tx zerocache.Tx) error {
+		p := tx.Multi()
+		set = p.Set("key", "value")
+		p.Do("BOGUS")
+		return p.Exec()
+	})
+
+	if err == nil || !strings.HasPrefix(err.Error(), "EXECABORT") {
+		t.Errorf("test Transaction with queue error failed, err: %v", err)
+	}
+	if set.Err() == nil {
+		t.Error("test Transaction with queue error failed, expected Set error")
+	}
+	if _, ok := s.Get("key"); ok {
+		t.Error("test Transaction with queue error failed, key should not be set")
+	}
+
+	// fn 的错误原样返回，连接归还后可以继续使用
+	errAbort := errors.New("abort")
+	if err := c.Transaction(func(tx zerocache.Tx) error { return errAbort }, "key"); err != errAbort {
+		t.Errorf("test Transaction failed, err: %v", err)
+	}
+	if err := c.Set("key", "value"); err != nil {
+		t.Errorf("test Set after Transaction failed: %s", err.Error())
+	}
+}
+
+func TestTransactionTimeout(t *testing.T) {
+	s, c := openServer(t)
+
+	// EXEC 较慢，事务的超时时间覆盖 fn 的全部执行过程
+	s.SetHandler(func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+		if cmd == "EXEC" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return nil, false
+	})
+
+	err := c.WithTimeout(150*time.Millisecond).Transaction(func(tx zerocache.Tx) error {
+		time.Sleep(100 * time.Millisecond)
+
+		p := tx.Multi()
+		p.Set("key", "value")
+		return p.Exec()
+	}, "key")
+
+	if err != context.DeadlineExceeded {
+		t.Errorf("test Transaction timeout failed, err: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 所有读数据库均处于熔断状态
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerState 熔断器状态
+type BreakerState int32
+
+const (
+	// BreakerClosed 正常
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 熔断中，请求直接失败
+	BreakerOpen
+	// BreakerHalfOpen 半开，允许少量请求探测数据库是否恢复
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig 读数据库熔断配置，每个 WrapReadDB 独立熔断
+type BreakerConfig struct {
+	// Window 统计周期，每个周期重新计数，默认 10 秒
+	Window time.Duration
+
+	// MinRequests 周期内请求数达到该值才会判断是否熔断，默认 20
+	MinRequests int
+
+	// ErrorRate 周期内失败比例达到该值时熔断，默认 0.5
+	// 数据不存在不算失败
+	ErrorRate float64
+
+	// SlowThreshold 耗时超过该值的请求也算失败，0 表示不判断耗时
+	SlowThreshold time.Duration
+
+	// OpenTimeout 熔断持续时间，之后进入半开状态，默认 5 秒
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests 半开状态下允许的探测请求数量，全部成功后恢复，默认 1
+	HalfOpenRequests int
+
+	// Placeholder 所有读数据库均熔断时，是否为主键设置短期缓存并返回 ErrNotFound
+	// 默认不设置，返回 ErrCircuitOpen
+	Placeholder bool
+}
+
+// breaker 熔断器，并发安全
+type breaker struct {
+	conf BreakerConfig
+
+	mu    sync.Mutex
+	state BreakerState
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt time.Time
+	probes   int
+	passed   int
+}
+
+func newBreaker(conf BreakerConfig) *breaker {
+	return &breaker{conf: conf, windowStart: time.Now()}
+}
+
+// WithCircuitBreaker 为每个读数据库开启熔断，熔断中的数据库会被跳过，选择其它读数据库
+func (e *entity) WithCircuitBreaker(conf BreakerConfig) Entity {
+	if conf.Window <= 0 {
+		conf.Window = 10 * time.Second
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = 20
+	}
+	if conf.ErrorRate <= 0 {
+		conf.ErrorRate = 0.5
+	}
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = 5 * time.Second
+	}
+	if conf.HalfOpenRequests <= 0 {
+		conf.HalfOpenRequests = 1
+	}
+
+	e.breakerConf = &conf
+	return e
+}
+
+// BreakerStates 每个读数据库的熔断状态，与 WithReadDB 的顺序一致
+func (e *entity) BreakerStates() []BreakerState {
+	states := make([]BreakerState, len(e.breakers))
+	for idx, b := range e.breakers {
+		states[idx] = b.State()
+	}
+	return states
+}
+
+// State 当前状态
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.conf.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow 是否允许请求，返回 true 时必须调用 done 或者 release
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.conf.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probes = 0
+		b.passed = 0
+	}
+
+	if b.probes >= b.conf.HalfOpenRequests {
+		return false
+	}
+	b.probes++
+	return true
+}
+
+// done 记录请求结果
+func (b *breaker) done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.open(now)
+			return
+		}
+		b.passed++
+		if b.passed >= b.conf.HalfOpenRequests {
+			b.state = BreakerClosed
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.conf.Window {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+
+		b.requests++
+		if failed {
+			b.failures++
+		}
+
+		if b.requests >= b.conf.MinRequests && float64(b.failures) >= b.conf.ErrorRate*float64(b.requests) {
+			b.open(now)
+		}
+	}
+}
+
+// release 请求被调用方取消，不计入结果，半开状态下归还探测名额
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+}
+
+// callReadDB 根据 v 选择读数据库并调用 fn
+//
+// 开启熔断时，跳过熔断中的读数据库，依次尝试下一个，全部熔断时返回 ErrCircuitOpen
+func (e *entity) callReadDB(ctx context.Context, v uint64, fn func(db WrapReadDB) error) error {
+	idx := e.readDBIndex(v)
+	if e.breakers == nil {
+		return fn(e.readDBs[idx])
+	}
+
+	n := len(e.readDBs)
+	for i := 0; i < n; i++ {
+		j := (idx + i) % n
+		b := e.breakers[j]
+		if !b.allow() {
+			continue
+		}
+
+		start := time.Now()
+		db := e.readDBs[j]
+		err := fn(db)
+
+		// 调用方取消的请求无法说明数据库是否健康
+		if err != nil && (ctx.Err() == context.Canceled || errors.Is(err, context.Canceled)) {
+			b.release()
+			return err
+		}
+
+		failed := err != nil && !errors.Is(err, ErrNotFound) &&
+			(db.ErrNotFound() == nil || !errors.Is(err, db.ErrNotFound()))
+		if b.conf.SlowThreshold > 0 && time.Since(start) > b.conf.SlowThreshold {
+			failed = true
+		}
+		b.done(failed)
+
+		return err
+	}
+
+	if e.st != nil {
+		e.st.incCircuitRejected()
+	}
+	return ErrCircuitOpen
+}
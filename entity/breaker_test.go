@@ -0,0 +1,114 @@
+package entity_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+var errUnavailable = errors.New("db unavailable")
+
+// flakyDB 可以模拟故障的读数据库
+type flakyDB struct {
+	scanDB
+	fail  int32
+	calls int32
+}
+
+func (db *flakyDB) GetCtx(ctx context.Context, out interface{}, id uint64) error {
+	atomic.AddInt32(&db.calls, 1)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&db.fail) == 1 {
+		return errUnavailable
+	}
+	return db.scanDB.GetCtx(ctx, out, id)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	db0, db1 := &flakyDB{fail: 1}, &flakyDB{}
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(db0, db1)
+	e.WithCircuitBreaker(zeroentity.BreakerConfig{MinRequests: 2, OpenTimeout: 50 * time.Millisecond})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	// 偶数主键使用 db0
+	for i := 0; i < 2; i++ {
+		if _, err := typed.Get(2); err != errUnavailable {
+			t.Fatalf("test Get failed, err: %v", err)
+		}
+	}
+	if states := e.BreakerStates(); states[0] != zeroentity.BreakerOpen || states[1] != zeroentity.BreakerClosed {
+		t.Fatalf("test BreakerStates failed: %v", states)
+	}
+
+	// db0 熔断，使用 db1
+	if a, err := typed.Get(2); err != nil || a.Username != "zero2" || db0.calls != 2 {
+		t.Errorf("test Get with replica failed, account: %v, err: %v, calls: %d", a, err, db0.calls)
+	}
+
+	// 全部熔断，快速失败
+	atomic.StoreInt32(&db1.fail, 1)
+	typed.Get(1)
+	typed.Get(1)
+	if _, err := typed.Get(1); err != zeroentity.ErrCircuitOpen {
+		t.Errorf("test Get with all open failed, err: %v", err)
+	}
+
+	// 恢复后，半开探测成功即关闭
+	atomic.StoreInt32(&db0.fail, 0)
+	atomic.StoreInt32(&db1.fail, 0)
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := typed.Get(2); err != nil {
+		t.Errorf("test Get after recover failed, err: %v", err)
+	}
+	if states := e.BreakerStates(); states[0] != zeroentity.BreakerClosed {
+		t.Errorf("test BreakerStates after recover failed: %v", states)
+	}
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	db := &flakyDB{fail: 1}
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(db)
+	e.WithCircuitBreaker(zeroentity.BreakerConfig{MinRequests: 1, OpenTimeout: 50 * time.Millisecond})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	typed.Get(2)
+	time.Sleep(60 * time.Millisecond)
+
+	// 半开状态下取消的探测不计入结果，也不占用探测名额
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := typed.GetCtx(ctx, 2); err != context.Canceled {
+		t.Errorf("test GetCtx canceled failed, err: %v", err)
+	}
+	// 等待共享查询结束
+	time.Sleep(10 * time.Millisecond)
+	if states := e.BreakerStates(); states[0] != zeroentity.BreakerHalfOpen {
+		t.Errorf("test BreakerStates after canceled failed: %v", states)
+	}
+
+	if _, err := typed.Get(2); err != errUnavailable {
+		t.Errorf("test Get after canceled failed, err: %v", err)
+	}
+	if states := e.BreakerStates(); states[0] != zeroentity.BreakerOpen {
+		t.Errorf("test BreakerStates after failed probe failed: %v", states)
+	}
+}
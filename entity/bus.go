@@ -0,0 +1,85 @@
+package entity
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// nodeSize 消息中实例标识的长度
+const nodeSize = 16
+
+// ErrInvalidMessage 无效的失效消息
+var ErrInvalidMessage = errors.New("invalid invalidation message")
+
+// InvalidationTransport 缓存失效消息的传输方式，用于多个实例之间同步删除本地缓存
+//
+// 可以使用 redis 的 Pub/Sub，见 entity/cache 中的 NewPubSubTransport
+// 测试时可以使用 NewMemoryTransport
+type InvalidationTransport interface {
+	// Publish 将消息发送到频道 topic
+	Publish(ctx context.Context, topic string, msg []byte) error
+
+	// Subscribe 订阅频道 topic，接收到消息时调用 onMessage
+	Subscribe(topic string, onMessage func(msg []byte)) error
+}
+
+// memoryTransport 进程内传输，用于测试
+type memoryTransport struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(msg []byte)
+}
+
+// NewMemoryTransport 创建一个进程内的失效消息传输，同一个 transport 上的实体相当于多个实例
+func NewMemoryTransport() InvalidationTransport {
+	return &memoryTransport{
+		handlers: make(map[string][]func(msg []byte)),
+	}
+}
+
+func (t *memoryTransport) Publish(ctx context.Context, topic string, msg []byte) error {
+	t.mu.RLock()
+	handlers := t.handlers[topic]
+	t.mu.RUnlock()
+
+	for _, handler := range handlers {
+		data := make([]byte, len(msg))
+		copy(data, msg)
+		handler(data)
+	}
+
+	return nil
+}
+
+func (t *memoryTransport) Subscribe(topic string, onMessage func(msg []byte)) error {
+	t.mu.Lock()
+	t.handlers[topic] = append(t.handlers[topic], onMessage)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// encodeInvalidation 消息格式: 实例标识 (16 字节) + 主键 (每个 8 字节)
+func encodeInvalidation(node string, ids ...uint64) []byte {
+	msg := make([]byte, nodeSize+8*len(ids))
+	copy(msg, node)
+	for idx, id := range ids {
+		binary.BigEndian.PutUint64(msg[nodeSize+8*idx:], id)
+	}
+	return msg
+}
+
+// decodeInvalidation 解析消息，返回实例标识与主键
+func decodeInvalidation(msg []byte) (string, []uint64, error) {
+	if len(msg) < nodeSize || (len(msg)-nodeSize)%8 != 0 {
+		return "", nil, ErrInvalidMessage
+	}
+
+	ids := make([]uint64, 0, (len(msg)-nodeSize)/8)
+	for offset := nodeSize; offset < len(msg); offset += 8 {
+		ids = append(ids, binary.BigEndian.Uint64(msg[offset:]))
+	}
+
+	return string(msg[:nodeSize]), ids, nil
+}
@@ -0,0 +1,44 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestInvalidationBus(t *testing.T) {
+	transport := zeroentity.NewMemoryTransport()
+
+	newInstance := func() (zeroentity.Entity, zeroentity.WrapCache) {
+		local := zeroentitycache.NewBigCache(time.Minute)
+		e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+		e.WithLocalCache(local)
+		e.WithInvalidationBus(transport, "account")
+		e.Build()
+		return e, local
+	}
+
+	e1, local1 := newInstance()
+	e2, local2 := newInstance()
+
+	a := accounts[1]
+	if err := e1.Set(&a, 1); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+	if err := e2.Set(&a, 1); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+
+	e1.RemoveCache(1)
+
+	if _, err := local1.Get(1); err != local1.ErrNotFound() {
+		t.Errorf("test RemoveCache failed, err: %v", err)
+	}
+	if _, err := local2.Get(1); err != local2.ErrNotFound() {
+		t.Errorf("test invalidation failed, err: %v", err)
+	}
+}
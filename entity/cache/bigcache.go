@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"strconv"
 	"time"
@@ -10,6 +11,9 @@ import (
 	zeroentity "github.com/zerogo-hub/zero-helper/entity"
 )
 
+// deadlineSize 每个值前附加的过期时间长度，unix 毫秒，0 表示仅受 eviction 控制
+const deadlineSize = 8
+
 // wrapBigcache 封装 bigcache
 type wrapBigcache struct {
 	cache       *bigcache.BigCache
@@ -20,7 +24,8 @@ type wrapBigcache struct {
 //
 // eviction 过期时间
 //
-// 缺点: 无法单独对指定的 key 单独设置过期时间
+// bigcache 无法单独对指定的 key 设置过期时间，SetExCtx 通过在值前附加过期时间实现，
+// 过期时间不能超过 eviction
 func NewBigCache(eviction time.Duration) zeroentity.WrapCache {
 	cache, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(eviction))
 	return &wrapBigcache{
@@ -30,14 +35,38 @@ func NewBigCache(eviction time.Duration) zeroentity.WrapCache {
 }
 
 func (w *wrapBigcache) Get(id uint64) ([]byte, error) {
-	return w.cache.Get(strconv.FormatUint(id, 10))
+	return w.GetCtx(context.Background(), id)
+}
+
+func (w *wrapBigcache) GetCtx(ctx context.Context, id uint64) ([]byte, error) {
+	key := strconv.FormatUint(id, 10)
+
+	data, err := w.cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < deadlineSize {
+		return nil, w.errNotFound
+	}
+
+	deadline := int64(binary.BigEndian.Uint64(data))
+	if deadline > 0 && deadline <= time.Now().UnixMilli() {
+		_ = w.cache.Delete(key)
+		return nil, w.errNotFound
+	}
+
+	return data[deadlineSize:], nil
 }
 
 func (w *wrapBigcache) MGet(ids ...uint64) ([]*zeroentity.Value, error) {
+	return w.MGetCtx(context.Background(), ids...)
+}
+
+func (w *wrapBigcache) MGetCtx(ctx context.Context, ids ...uint64) ([]*zeroentity.Value, error) {
 	// 不支持批量获取，改为遍历获取
 	results := make([]*zeroentity.Value, 0, len(ids))
 	for _, id := range ids {
-		val, err := w.Get(id)
+		val, err := w.GetCtx(ctx, id)
 		results = append(results, &zeroentity.Value{ID: id, Val: val, Err: err})
 	}
 
@@ -45,16 +74,43 @@ func (w *wrapBigcache) MGet(ids ...uint64) ([]*zeroentity.Value, error) {
 }
 
 func (w *wrapBigcache) Set(id uint64, in []byte) error {
-	return w.cache.Set(strconv.FormatUint(id, 10), in)
+	return w.SetExCtx(context.Background(), id, in, 0)
+}
+
+func (w *wrapBigcache) SetCtx(ctx context.Context, id uint64, in []byte) error {
+	return w.SetExCtx(ctx, id, in, 0)
+}
+
+func (w *wrapBigcache) SetExCtx(ctx context.Context, id uint64, in []byte, ttl time.Duration) error {
+	data := make([]byte, deadlineSize+len(in))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(data, uint64(time.Now().Add(ttl).UnixMilli()))
+	}
+	copy(data[deadlineSize:], in)
+
+	return w.cache.Set(strconv.FormatUint(id, 10), data)
 }
 
 func (w *wrapBigcache) MSet(ids []uint64, datas [][]byte) error {
-	if len(ids) != len(datas) {
+	return w.MSetExCtx(context.Background(), ids, datas, nil)
+}
+
+func (w *wrapBigcache) MSetCtx(ctx context.Context, ids []uint64, datas [][]byte) error {
+	return w.MSetExCtx(ctx, ids, datas, nil)
+}
+
+func (w *wrapBigcache) MSetExCtx(ctx context.Context, ids []uint64, datas [][]byte, ttls []time.Duration) error {
+	if len(ids) != len(datas) || (ttls != nil && len(ids) != len(ttls)) {
 		return errors.New("invalid length")
 	}
 
 	for idx, id := range ids {
-		if err := w.Set(id, datas[idx]); err != nil {
+		var ttl time.Duration
+		if ttls != nil {
+			ttl = ttls[idx]
+		}
+
+		if err := w.SetExCtx(ctx, id, datas[idx], ttl); err != nil {
 			return err
 		}
 	}
@@ -63,12 +119,20 @@ func (w *wrapBigcache) MSet(ids []uint64, datas [][]byte) error {
 }
 
 func (w *wrapBigcache) Delete(id uint64) error {
+	return w.DeleteCtx(context.Background(), id)
+}
+
+func (w *wrapBigcache) DeleteCtx(ctx context.Context, id uint64) error {
 	return w.cache.Delete(strconv.FormatUint(id, 10))
 }
 
 func (w *wrapBigcache) MDelete(ids ...uint64) error {
+	return w.MDeleteCtx(context.Background(), ids...)
+}
+
+func (w *wrapBigcache) MDeleteCtx(ctx context.Context, ids ...uint64) error {
 	for _, id := range ids {
-		if err := w.Delete(id); err != nil && err != w.errNotFound {
+		if err := w.DeleteCtx(ctx, id); err != nil && err != w.errNotFound {
 			return err
 		}
 	}
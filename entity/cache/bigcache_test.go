@@ -0,0 +1,34 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+)
+
+func TestBigCacheSetEx(t *testing.T) {
+	c := zeroentitycache.NewBigCache(time.Minute)
+	ctx := context.Background()
+
+	if err := c.SetExCtx(ctx, 1, []byte("hello"), 50*time.Millisecond); err != nil {
+		t.Fatalf("test SetExCtx failed: %s", err.Error())
+	}
+	if err := c.Set(2, []byte("world")); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+
+	if bs, err := c.Get(1); err != nil || string(bs) != "hello" {
+		t.Errorf("test Get failed, val: %s, err: %v", bs, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := c.Get(1); err != c.ErrNotFound() {
+		t.Errorf("test Get after expired failed, err: %v", err)
+	}
+	if bs, err := c.Get(2); err != nil || string(bs) != "world" {
+		t.Errorf("test Get without ttl failed, val: %s, err: %v", bs, err)
+	}
+}
@@ -0,0 +1,22 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	"github.com/zerogo-hub/zero-helper/entity/entitytest"
+)
+
+func TestBigCacheConformance(t *testing.T) {
+	entitytest.CacheConformance(t, func() zeroentity.WrapCache {
+		return zeroentitycache.NewBigCache(time.Minute)
+	})
+}
+
+func TestFreeCacheConformance(t *testing.T) {
+	entitytest.CacheConformance(t, func() zeroentity.WrapCache {
+		return zeroentitycache.NewFreeCache(512*1024, time.Minute)
+	})
+}
@@ -1 +1,126 @@
 package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/coocood/freecache"
+
+	zerobytes "github.com/zerogo-hub/zero-helper/bytes"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// wrapFreecache 封装 freecache
+type wrapFreecache struct {
+	cache       *freecache.Cache
+	expire      int
+	errNotFound error
+}
+
+// NewFreeCache ..
+//
+// size 缓存大小，单位字节，最小 512KB
+// expire 过期时间，精度为秒，0 表示不过期
+//
+// 与 bigcache 相比，可以对每个 key 单独设置过期时间
+func NewFreeCache(size int, expire time.Duration) zeroentity.WrapCache {
+	return &wrapFreecache{
+		cache:       freecache.NewCache(size),
+		expire:      int(expire / time.Second),
+		errNotFound: freecache.ErrNotFound,
+	}
+}
+
+func (w *wrapFreecache) Get(id uint64) ([]byte, error) {
+	return w.GetCtx(context.Background(), id)
+}
+
+func (w *wrapFreecache) GetCtx(ctx context.Context, id uint64) ([]byte, error) {
+	return w.cache.Get(zerobytes.PutUint64(id))
+}
+
+func (w *wrapFreecache) MGet(ids ...uint64) ([]*zeroentity.Value, error) {
+	return w.MGetCtx(context.Background(), ids...)
+}
+
+func (w *wrapFreecache) MGetCtx(ctx context.Context, ids ...uint64) ([]*zeroentity.Value, error) {
+	results := make([]*zeroentity.Value, 0, len(ids))
+	for _, id := range ids {
+		val, err := w.GetCtx(ctx, id)
+		results = append(results, &zeroentity.Value{ID: id, Val: val, Err: err})
+	}
+
+	return results, nil
+}
+
+func (w *wrapFreecache) Set(id uint64, in []byte) error {
+	return w.SetExCtx(context.Background(), id, in, 0)
+}
+
+func (w *wrapFreecache) SetCtx(ctx context.Context, id uint64, in []byte) error {
+	return w.SetExCtx(ctx, id, in, 0)
+}
+
+// SetExCtx 过期时间精度为秒，不足 1 秒按 1 秒计算
+func (w *wrapFreecache) SetExCtx(ctx context.Context, id uint64, in []byte, ttl time.Duration) error {
+	expire := w.expire
+	if ttl > 0 {
+		expire = int((ttl + time.Second - 1) / time.Second)
+	}
+
+	return w.cache.Set(zerobytes.PutUint64(id), in, expire)
+}
+
+func (w *wrapFreecache) MSet(ids []uint64, datas [][]byte) error {
+	return w.MSetExCtx(context.Background(), ids, datas, nil)
+}
+
+func (w *wrapFreecache) MSetCtx(ctx context.Context, ids []uint64, datas [][]byte) error {
+	return w.MSetExCtx(ctx, ids, datas, nil)
+}
+
+func (w *wrapFreecache) MSetExCtx(ctx context.Context, ids []uint64, datas [][]byte, ttls []time.Duration) error {
+	if len(ids) != len(datas) || (ttls != nil && len(ids) != len(ttls)) {
+		return errors.New("invalid length")
+	}
+
+	for idx, id := range ids {
+		var ttl time.Duration
+		if ttls != nil {
+			ttl = ttls[idx]
+		}
+
+		if err := w.SetExCtx(ctx, id, datas[idx], ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *wrapFreecache) Delete(id uint64) error {
+	return w.DeleteCtx(context.Background(), id)
+}
+
+func (w *wrapFreecache) DeleteCtx(ctx context.Context, id uint64) error {
+	if !w.cache.Del(zerobytes.PutUint64(id)) {
+		return w.errNotFound
+	}
+	return nil
+}
+
+func (w *wrapFreecache) MDelete(ids ...uint64) error {
+	return w.MDeleteCtx(context.Background(), ids...)
+}
+
+func (w *wrapFreecache) MDeleteCtx(ctx context.Context, ids ...uint64) error {
+	for _, id := range ids {
+		w.cache.Del(zerobytes.PutUint64(id))
+	}
+	return nil
+}
+
+func (w *wrapFreecache) ErrNotFound() error {
+	return w.errNotFound
+}
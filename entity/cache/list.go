@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// memoryList 内存中的一个列表
+type memoryList struct {
+	members  []zeroentity.ListMember
+	deadline time.Time
+}
+
+// memoryListStore 内存列表，适用于单机或者测试
+type memoryListStore struct {
+	mu     sync.Mutex
+	lists  map[string]*memoryList
+	owners map[uint64]map[string]struct{}
+}
+
+// NewMemoryListStore 创建一个内存列表
+func NewMemoryListStore() zeroentity.ListStore {
+	return &memoryListStore{
+		lists:  make(map[string]*memoryList),
+		owners: make(map[uint64]map[string]struct{}),
+	}
+}
+
+func (s *memoryListStore) Replace(ctx context.Context, key string, members []zeroentity.ListMember, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := &memoryList{members: make([]zeroentity.ListMember, 0, len(members))}
+	if ttl > 0 {
+		l.deadline = time.Now().Add(ttl)
+	}
+	s.lists[key] = l
+	s.add(key, l, members)
+
+	return nil
+}
+
+func (s *memoryListStore) Add(ctx context.Context, key string, members ...zeroentity.ListMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l := s.get(key); l != nil {
+		s.add(key, l, members)
+	}
+
+	return nil
+}
+
+func (s *memoryListStore) Remove(ctx context.Context, key string, ids ...uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.get(key)
+	if l == nil {
+		return nil
+	}
+
+	removed := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		removed[id] = struct{}{}
+	}
+
+	members := l.members[:0]
+	for _, m := range l.members {
+		if _, ok := removed[m.ID]; !ok {
+			members = append(members, m)
+		}
+	}
+	l.members = members
+
+	return nil
+}
+
+func (s *memoryListStore) Range(ctx context.Context, key string, start, stop int, desc bool) ([]uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.get(key)
+	if l == nil {
+		return nil, zeroentity.ErrListNotFound
+	}
+
+	n := len(l.members)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+
+	ids := make([]uint64, 0)
+	for idx := start; idx <= stop; idx++ {
+		ids = append(ids, l.at(idx, desc).ID)
+	}
+
+	return ids, nil
+}
+
+func (s *memoryListStore) RangeByScore(ctx context.Context, key string, min, max float64, offset, count int, desc bool) ([]uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.get(key)
+	if l == nil {
+		return nil, zeroentity.ErrListNotFound
+	}
+
+	ids := make([]uint64, 0)
+	for idx := range l.members {
+		m := l.at(idx, desc)
+		if m.Score < min || m.Score > max {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count > 0 && len(ids) >= count {
+			break
+		}
+		ids = append(ids, m.ID)
+	}
+
+	return ids, nil
+}
+
+func (s *memoryListStore) Count(ctx context.Context, key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.get(key)
+	if l == nil {
+		return 0, zeroentity.ErrListNotFound
+	}
+
+	return len(l.members), nil
+}
+
+func (s *memoryListStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.lists, key)
+	}
+
+	return nil
+}
+
+func (s *memoryListStore) Owners(ctx context.Context, id uint64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.owners[id]))
+	for key := range s.owners[id] {
+		if s.get(key) != nil {
+			keys = append(keys, key)
+		} else {
+			delete(s.owners[id], key)
+		}
+	}
+	if len(s.owners[id]) == 0 {
+		delete(s.owners, id)
+	}
+
+	return keys, nil
+}
+
+// get 获取未过期的列表，不存在时返回 nil
+func (s *memoryListStore) get(key string) *memoryList {
+	l, ok := s.lists[key]
+	if !ok {
+		return nil
+	}
+
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		delete(s.lists, key)
+		return nil
+	}
+
+	return l
+}
+
+// add 加入成员并排序，已存在的成员更新 Score
+func (s *memoryListStore) add(key string, l *memoryList, members []zeroentity.ListMember) {
+	index := make(map[uint64]int, len(l.members))
+	for idx, m := range l.members {
+		index[m.ID] = idx
+	}
+
+	for _, m := range members {
+		if idx, ok := index[m.ID]; ok {
+			l.members[idx].Score = m.Score
+		} else {
+			index[m.ID] = len(l.members)
+			l.members = append(l.members, m)
+		}
+
+		if s.owners[m.ID] == nil {
+			s.owners[m.ID] = make(map[string]struct{})
+		}
+		s.owners[m.ID][key] = struct{}{}
+	}
+
+	sort.Slice(l.members, func(i, j int) bool {
+		if l.members[i].Score != l.members[j].Score {
+			return l.members[i].Score < l.members[j].Score
+		}
+		return l.members[i].ID < l.members[j].ID
+	})
+}
+
+// at 第 idx 个成员，desc 为 true 时从后往前
+func (l *memoryList) at(idx int, desc bool) zeroentity.ListMember {
+	if desc {
+		return l.members[len(l.members)-1-idx]
+	}
+	return l.members[idx]
+}
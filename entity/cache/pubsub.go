@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// wrapPubSub 基于 redis Pub/Sub 的失效消息传输
+type wrapPubSub struct {
+	cache zerocache.Cache
+}
+
+// NewPubSubTransport 创建一个基于 redis Pub/Sub 的失效消息传输
+//
+// 订阅断开后会自动重新订阅，断开期间的消息会丢失，本地缓存依旧依赖过期时间兜底
+func NewPubSubTransport(cache zerocache.Cache) zeroentity.InvalidationTransport {
+	return &wrapPubSub{cache: cache}
+}
+
+func (w *wrapPubSub) Publish(ctx context.Context, topic string, msg []byte) error {
+	_, err := w.cache.Publish(topic, msg)
+	return err
+}
+
+func (w *wrapPubSub) Subscribe(topic string, onMessage func(msg []byte)) error {
+	return w.cache.Subscribe(nil, func(channel string, data []byte) error {
+		onMessage(data)
+		return nil
+	}, 3, -1, topic)
+}
@@ -0,0 +1,40 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+)
+
+func TestPubSubTransport(t *testing.T) {
+	s, c := newRedis(t)
+	transport := zeroentitycache.NewPubSubTransport(c)
+
+	received := make(chan string, 1)
+	if err := transport.Subscribe("invalidate", func(msg []byte) {
+		received <- string(msg)
+	}); err != nil {
+		t.Fatalf("test Subscribe failed: %s", err.Error())
+	}
+
+	// 空闲时间超过连接的读取超时 (默认 500ms) 后，订阅依旧有效
+	time.Sleep(800 * time.Millisecond)
+	if n := s.Subscribers("invalidate"); n != 1 {
+		t.Fatalf("test Subscribe failed, subscribers: %d", n)
+	}
+
+	if err := transport.Publish(context.Background(), "invalidate", []byte("1001")); err != nil {
+		t.Fatalf("test Publish failed: %s", err.Error())
+	}
+
+	select {
+	case msg := <-received:
+		if msg != "1001" {
+			t.Errorf("test Subscribe failed, msg: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("test Subscribe failed, message lost")
+	}
+}
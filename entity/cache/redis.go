@@ -1,3 +1,204 @@
 package cache
 
-// cache-aside/5/storeage/redis.go
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// placeholderTTL 未指定过期时间时，短期缓存 (数据不存在) 在 redis 中的最长有效期
+const placeholderTTL = time.Minute
+
+// wrapRedis 封装 redis，作为远端缓存
+type wrapRedis struct {
+	cache       zerocache.Cache
+	prefix      string
+	ttl         time.Duration
+	errNotFound error
+}
+
+// NewRedisCache ..
+//
+// prefix 键前缀，如 "account:"，最终的键为 prefix + id
+// ttl 过期时间，0 表示永不过期
+func NewRedisCache(cache zerocache.Cache, prefix string, ttl time.Duration) zeroentity.WrapCache {
+	return &wrapRedis{
+		cache:       cache,
+		prefix:      prefix,
+		ttl:         ttl,
+		errNotFound: zerocache.ErrNil,
+	}
+}
+
+func (w *wrapRedis) Get(id uint64) ([]byte, error) {
+	return w.GetCtx(context.Background(), id)
+}
+
+func (w *wrapRedis) GetCtx(ctx context.Context, id uint64) ([]byte, error) {
+	conn := w.cache.Conn()
+	defer conn.Close()
+
+	return redis.Bytes(redis.DoContext(conn, ctx, "GET", w.key(id)))
+}
+
+func (w *wrapRedis) MGet(ids ...uint64) ([]*zeroentity.Value, error) {
+	return w.MGetCtx(context.Background(), ids...)
+}
+
+func (w *wrapRedis) MGetCtx(ctx context.Context, ids ...uint64) ([]*zeroentity.Value, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, w.key(id))
+	}
+
+	conn := w.cache.Conn()
+	defer conn.Close()
+
+	vals, err := redis.ByteSlices(redis.DoContext(conn, ctx, "MGET", keys...))
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != len(ids) {
+		return nil, errors.New("invalid length")
+	}
+
+	results := make([]*zeroentity.Value, 0, len(ids))
+	for idx, id := range ids {
+		v := &zeroentity.Value{ID: id, Val: vals[idx]}
+		if vals[idx] == nil {
+			v.Err = w.errNotFound
+		}
+		results = append(results, v)
+	}
+
+	return results, nil
+}
+
+func (w *wrapRedis) Set(id uint64, in []byte) error {
+	return w.SetExCtx(context.Background(), id, in, 0)
+}
+
+func (w *wrapRedis) SetCtx(ctx context.Context, id uint64, in []byte) error {
+	return w.SetExCtx(ctx, id, in, 0)
+}
+
+func (w *wrapRedis) SetExCtx(ctx context.Context, id uint64, in []byte, ttl time.Duration) error {
+	conn := w.cache.Conn()
+	defer conn.Close()
+
+	_, err := redis.DoContext(conn, ctx, "SET", w.args(id, in, ttl)...)
+	return err
+}
+
+func (w *wrapRedis) MSet(ids []uint64, datas [][]byte) error {
+	return w.MSetExCtx(context.Background(), ids, datas, nil)
+}
+
+func (w *wrapRedis) MSetCtx(ctx context.Context, ids []uint64, datas [][]byte) error {
+	return w.MSetExCtx(ctx, ids, datas, nil)
+}
+
+// MSetExCtx 通过管道批量写入，每个键单独设置过期时间
+func (w *wrapRedis) MSetExCtx(ctx context.Context, ids []uint64, datas [][]byte, ttls []time.Duration) error {
+	if len(ids) != len(datas) || (ttls != nil && len(ids) != len(ttls)) {
+		return errors.New("invalid length")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	conn := w.cache.Conn()
+	defer conn.Close()
+
+	for idx, id := range ids {
+		var ttl time.Duration
+		if ttls != nil {
+			ttl = ttls[idx]
+		}
+
+		if err := conn.Send("SET", w.args(id, datas[idx], ttl)...); err != nil {
+			return err
+		}
+	}
+
+	// 命令为空时，刷新管道并接收所有回复
+	replies, err := redis.Values(redis.DoContext(conn, ctx, ""))
+	if err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *wrapRedis) Delete(id uint64) error {
+	return w.DeleteCtx(context.Background(), id)
+}
+
+func (w *wrapRedis) DeleteCtx(ctx context.Context, id uint64) error {
+	conn := w.cache.Conn()
+	defer conn.Close()
+
+	_, err := redis.DoContext(conn, ctx, "DEL", w.key(id))
+	return err
+}
+
+func (w *wrapRedis) MDelete(ids ...uint64) error {
+	return w.MDeleteCtx(context.Background(), ids...)
+}
+
+func (w *wrapRedis) MDeleteCtx(ctx context.Context, ids ...uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, w.key(id))
+	}
+
+	conn := w.cache.Conn()
+	defer conn.Close()
+
+	_, err := redis.DoContext(conn, ctx, "DEL", keys...)
+	return err
+}
+
+func (w *wrapRedis) ErrNotFound() error {
+	return w.errNotFound
+}
+
+func (w *wrapRedis) key(id uint64) string {
+	return w.prefix + strconv.FormatUint(id, 10)
+}
+
+// args 生成 SET 命令参数，ttl <= 0 时使用默认过期时间
+func (w *wrapRedis) args(id uint64, in []byte, ttl time.Duration) []interface{} {
+	if ttl <= 0 {
+		ttl = w.ttl
+		if zeroentity.IsEmptyPlaceholder(in) && (ttl <= 0 || ttl > placeholderTTL) {
+			ttl = placeholderTTL
+		}
+	}
+
+	if ttl <= 0 {
+		return []interface{}{w.key(id), in}
+	}
+
+	return []interface{}{w.key(id), in, "PX", ttl.Milliseconds()}
+}
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// listSentinel 有序集合中的哨兵成员，score 为 -inf，用于区分空列表与不存在的列表
+const listSentinel = ""
+
+// replaceListScript 覆盖写入列表
+// KEYS[1] 列表的键，ARGV[1] 过期时间 (毫秒，0 表示永不过期)，之后为 score member ...
+var replaceListScript = redis.NewScript(1, `
+redis.call('DEL', KEYS[1])
+redis.call('ZADD', KEYS[1], '-inf', '')
+for i = 2, #ARGV, 2 do
+	redis.call('ZADD', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+if tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// addListScript 列表存在时加入成员
+// KEYS[1] 列表的键，ARGV 为 score member ...
+var addListScript = redis.NewScript(1, `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+return redis.call('ZADD', KEYS[1], unpack(ARGV))
+`)
+
+// redisListStore 使用 redis 有序集合保存列表
+//
+// 列表中包含一个 score 为 -inf 的哨兵成员，读取时过滤
+// 反向索引 成员 -> 列表 保存在集合 prefix + "member:" + id 中
+type redisListStore struct {
+	cache  zerocache.Cache
+	prefix string
+}
+
+// NewRedisListStore ..
+//
+// prefix 键前缀，如 "list:"，列表的键为 prefix + key
+func NewRedisListStore(cache zerocache.Cache, prefix string) zeroentity.ListStore {
+	return &redisListStore{cache: cache, prefix: prefix}
+}
+
+func (s *redisListStore) Replace(ctx context.Context, key string, members []zeroentity.ListMember, ttl time.Duration) error {
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	args := make([]interface{}, 0, 2+len(members)*2)
+	args = append(args, s.key(key), ttl.Milliseconds())
+	for _, m := range members {
+		args = append(args, formatScore(m.Score), strconv.FormatUint(m.ID, 10))
+	}
+
+	if _, err := replaceListScript.DoContext(ctx, conn, args...); err != nil {
+		return err
+	}
+
+	return s.addOwners(ctx, conn, key, members, ttl)
+}
+
+func (s *redisListStore) Add(ctx context.Context, key string, members ...zeroentity.ListMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	args := make([]interface{}, 0, 1+len(members)*2)
+	args = append(args, s.key(key))
+	for _, m := range members {
+		args = append(args, formatScore(m.Score), strconv.FormatUint(m.ID, 10))
+	}
+
+	added, err := redis.Int(addListScript.DoContext(ctx, conn, args...))
+	if err != nil || added == 0 {
+		return err
+	}
+
+	return s.addOwners(ctx, conn, key, members, 0)
+}
+
+func (s *redisListStore) Remove(ctx context.Context, key string, ids ...uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 1+len(ids))
+	args = append(args, s.key(key))
+	for _, id := range ids {
+		args = append(args, strconv.FormatUint(id, 10))
+	}
+
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	_, err := redis.DoContext(conn, ctx, "ZREM", args...)
+	return err
+}
+
+func (s *redisListStore) Range(ctx context.Context, key string, start, stop int, desc bool) ([]uint64, error) {
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	cmd := "ZREVRANGE"
+	if !desc {
+		// 跳过排在最前面的哨兵
+		cmd = "ZRANGE"
+		if start >= 0 {
+			start++
+		}
+		if stop >= 0 {
+			stop++
+		}
+	}
+
+	members, err := redis.Strings(redis.DoContext(conn, ctx, cmd, s.key(key), start, stop))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.parse(ctx, conn, key, members)
+}
+
+func (s *redisListStore) RangeByScore(ctx context.Context, key string, min, max float64, offset, count int, desc bool) ([]uint64, error) {
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	if count <= 0 {
+		count = -1
+	}
+
+	// 不包含哨兵
+	lower := formatScore(min)
+	if math.IsInf(min, -1) {
+		lower = "(-inf"
+	}
+
+	var members []string
+	var err error
+	if desc {
+		members, err = redis.Strings(redis.DoContext(conn, ctx, "ZREVRANGEBYSCORE", s.key(key), formatScore(max), lower, "LIMIT", offset, count))
+	} else {
+		members, err = redis.Strings(redis.DoContext(conn, ctx, "ZRANGEBYSCORE", s.key(key), lower, formatScore(max), "LIMIT", offset, count))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.parse(ctx, conn, key, members)
+}
+
+func (s *redisListStore) Count(ctx context.Context, key string) (int, error) {
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	n, err := redis.Int(redis.DoContext(conn, ctx, "ZCARD", s.key(key)))
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, zeroentity.ErrListNotFound
+	}
+
+	return n - 1, nil
+}
+
+func (s *redisListStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, s.key(key))
+	}
+
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	_, err := redis.DoContext(conn, ctx, "DEL", args...)
+	return err
+}
+
+func (s *redisListStore) Owners(ctx context.Context, id uint64) ([]string, error) {
+	conn := s.cache.Conn()
+	defer conn.Close()
+
+	return redis.Strings(redis.DoContext(conn, ctx, "SMEMBERS", s.memberKey(id)))
+}
+
+// addOwners 通过管道记录反向索引，ttl > 0 时反向索引与列表同时过期
+func (s *redisListStore) addOwners(ctx context.Context, conn redis.Conn, key string, members []zeroentity.ListMember, ttl time.Duration) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	for _, m := range members {
+		if err := conn.Send("SADD", s.memberKey(m.ID), key); err != nil {
+			return err
+		}
+		if ttl > 0 {
+			if err := conn.Send("PEXPIRE", s.memberKey(m.ID), ttl.Milliseconds()); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := redis.DoContext(conn, ctx, "")
+	return err
+}
+
+// parse 过滤哨兵并解析主键，结果为空时检查列表是否存在
+func (s *redisListStore) parse(ctx context.Context, conn redis.Conn, key string, members []string) ([]uint64, error) {
+	ids := make([]uint64, 0, len(members))
+	for _, member := range members {
+		if member == listSentinel {
+			continue
+		}
+		id, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if len(members) == 0 {
+		exists, err := redis.Bool(redis.DoContext(conn, ctx, "EXISTS", s.key(key)))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, zeroentity.ErrListNotFound
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *redisListStore) key(key string) string {
+	return s.prefix + key
+}
+
+func (s *redisListStore) memberKey(id uint64) string {
+	return s.prefix + "member:" + strconv.FormatUint(id, 10)
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
@@ -0,0 +1,42 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	"github.com/zerogo-hub/zero-helper/entity/entitytest"
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+)
+
+func newRedis(t *testing.T) (*cachetest.Server, zerocache.Cache) {
+	s := cachetest.NewServer(t)
+
+	c := zerocache.NewCache(zerocache.WithHost(s.Host()), zerocache.WithPort(s.Port()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return s, c
+}
+
+func TestRedisCacheConformance(t *testing.T) {
+	entitytest.CacheConformance(t, func() zeroentity.WrapCache {
+		_, c := newRedis(t)
+		return zeroentitycache.NewRedisCache(c, "account:", time.Minute)
+	})
+}
+
+func TestRedisCacheDown(t *testing.T) {
+	s, c := newRedis(t)
+	w := zeroentitycache.NewRedisCache(c, "account:", time.Minute)
+
+	s.Close()
+
+	if vals, err := w.MGet(1, 2); err == nil {
+		t.Errorf("test MGet with redis down failed, vals: %v", vals)
+	}
+}
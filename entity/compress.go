@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"errors"
+
+	zerocompress "github.com/zerogo-hub/zero-helper/compress"
+)
+
+// ErrCompressNotSet 数据已压缩，但未设置压缩方式
+var ErrCompressNotSet = errors.New("compress not set")
+
+// flagCompressed 信封标识位，数据已压缩
+const flagCompressed = 1 << 0
+
+// WithCompress 压缩缓存中的数据，编码后长度不小于 minSize 的数据才会压缩
+//
+// 压缩与否记录在信封的标识位中，压缩与未压缩的数据可以共存
+// 更换压缩方式时需要同时修改 WithSchemaVersion，否则旧数据无法解压
+func (e *entity) WithCompress(compress zerocompress.Compress, minSize int) Entity {
+	e.compress = compress
+	e.compressMinSize = minSize
+	return e
+}
+
+// compressPayload 压缩数据，压缩后没有变小时不压缩
+func (e *entity) compressPayload(env *envelope) error {
+	if e.compress == nil || len(env.payload) < e.compressMinSize {
+		return nil
+	}
+
+	bs, err := e.compress.Compress(env.payload)
+	if err != nil {
+		return err
+	}
+
+	if len(bs) < len(env.payload) {
+		env.payload = bs
+		env.flags |= flagCompressed
+	}
+	return nil
+}
+
+// uncompressPayload 解压数据
+func (e *entity) uncompressPayload(env *envelope) error {
+	if env.flags&flagCompressed == 0 {
+		return nil
+	}
+
+	if e.compress == nil {
+		return ErrCompressNotSet
+	}
+
+	bs, err := e.compress.Uncompress(env.payload)
+	if err != nil {
+		return err
+	}
+
+	env.payload = bs
+	env.flags &^= flagCompressed
+	return nil
+}
@@ -0,0 +1,62 @@
+package entity_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zerogzip "github.com/zerogo-hub/zero-helper/compress/gzip"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestCompress(t *testing.T) {
+	local := zeroentitycache.NewBigCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithCompress(zerogzip.NewGZip(), 64)
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+	long := strings.Repeat("zero", 256)
+
+	typed.Set(account{UUID: 1, Username: long}, 1)
+	typed.Set(account{UUID: 2, Username: "zero2"}, 2)
+
+	bs, err := local.Get(1)
+	if err != nil || len(bs) >= len(long) {
+		t.Errorf("test compress failed, len: %d, err: %v", len(bs), err)
+	}
+	bs, err = local.Get(2)
+	if err != nil || !bytes.Contains(bs, []byte("zero2")) {
+		t.Errorf("test compress with small value failed, bytes: %v, err: %v", bs, err)
+	}
+
+	for id, name := range map[uint64]string{1: long, 2: "zero2"} {
+		a, err := typed.Get(id)
+		if err != nil || a.Username != name {
+			t.Errorf("test Get failed, id: %d, err: %v", id, err)
+		}
+	}
+
+	// 未设置压缩方式的实例读取到压缩的数据，视为未命中
+	queries := 0
+	plain := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	plain.WithLocalCache(local)
+	plain.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		queries++
+		return queryAccounts(out, ids...)
+	})
+	plain.Build()
+	defer plain.Close()
+
+	a, err := zeroentity.NewTyped[account](plain).Get(1)
+	if err != nil || a.Username != "zero1" || queries != 1 {
+		t.Errorf("test Get without compress failed, account: %v, queries: %d, err: %v", a, queries, err)
+	}
+}
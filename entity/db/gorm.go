@@ -18,6 +18,8 @@ type wrapGorm struct {
 	errNotFound error
 }
 
+var _ zeroentity.IDScanner = (*wrapGorm)(nil)
+
 func newGormRead(db zerodatabase.Database) zeroentity.WrapReadDB {
 	return &wrapGorm{
 		db:          db,
@@ -62,11 +64,19 @@ func NewGormWrite(db zerodatabase.Database) zeroentity.WrapWriteDB {
 }
 
 func (w *wrapGorm) Get(out interface{}, id uint64) error {
-	return w.db.DB().First(out, id).Error
+	return w.GetCtx(context.Background(), out, id)
+}
+
+func (w *wrapGorm) GetCtx(ctx context.Context, out interface{}, id uint64) error {
+	return w.db.DB().WithContext(ctx).First(out, id).Error
 }
 
 func (w *wrapGorm) MGet(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
-	tx := w.db.DB().Find(out, ids)
+	return w.MGetCtx(context.Background(), out, ids...)
+}
+
+func (w *wrapGorm) MGetCtx(ctx context.Context, out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	tx := w.db.DB().WithContext(ctx).Find(out, ids)
 	if tx.Error != nil {
 		return nil, nil, tx.Error
 	}
@@ -123,18 +133,62 @@ func (w *wrapGorm) parseQueryResults(tx *gorm.DB, out interface{}) ([]uint64, []
 	return primaryKeys, objects, nil
 }
 
+func (w *wrapGorm) ScanIDs(model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	return w.ScanIDsCtx(context.Background(), model, afterID, limit)
+}
+
+// ScanIDsCtx 按照主键升序扫描主键，model 用于确定表名与主键列
+func (w *wrapGorm) ScanIDsCtx(ctx context.Context, model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	tx := w.db.DB().WithContext(ctx).Model(model)
+	if err := tx.Statement.Parse(model); err != nil {
+		return nil, err
+	}
+
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil, errors.New("primary key not found")
+	}
+
+	var ids []uint64
+	err := tx.Where(field.DBName+" > ?", afterID).Order(field.DBName).Limit(limit).Pluck(field.DBName, &ids).Error
+	return ids, err
+}
+
 func (w *wrapGorm) Update(in interface{}) error {
-	return w.db.DB().Save(in).Error
+	return w.UpdateCtx(context.Background(), in)
+}
+
+func (w *wrapGorm) UpdateCtx(ctx context.Context, in interface{}) error {
+	return w.session(ctx).Save(in).Error
 }
 
 func (w *wrapGorm) Delete(model interface{}, id uint64) error {
-	return w.db.DB().Unscoped().Delete(model, id).Error
+	return w.DeleteCtx(context.Background(), model, id)
+}
+
+func (w *wrapGorm) DeleteCtx(ctx context.Context, model interface{}, id uint64) error {
+	return w.session(ctx).Unscoped().Delete(model, id).Error
 }
 
 func (w *wrapGorm) MDelete(model interface{}, ids ...uint64) error {
-	return nil
+	return w.MDeleteCtx(context.Background(), model, ids...)
+}
+
+func (w *wrapGorm) MDeleteCtx(ctx context.Context, model interface{}, ids ...uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return w.session(ctx).Unscoped().Delete(model, ids).Error
 }
 
 func (w *wrapGorm) ErrNotFound() error {
 	return w.errNotFound
 }
+
+// session 写入时使用的连接，ctx 中存在 Transaction 开启的事务时使用该事务
+func (w *wrapGorm) session(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(gormTxKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return w.db.DB().WithContext(ctx)
+}
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	zerodatabase "github.com/zerogo-hub/zero-helper/database"
+	zeroentitydb "github.com/zerogo-hub/zero-helper/entity/db"
+)
+
+// gormDatabase 使用指定 gorm 连接的 Database
+type gormDatabase struct {
+	zerodatabase.Database
+	db *gorm.DB
+}
+
+func (d gormDatabase) DB() *gorm.DB { return d.db }
+
+func openGorm(t *testing.T) (*fakeDriver, zerodatabase.Database) {
+	d, sqlDB := openFake(t)
+
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("open gorm failed: %s", err.Error())
+	}
+	return d, gormDatabase{db: db}
+}
+
+func TestGormDelete(t *testing.T) {
+	d, db := openGorm(t)
+	w := zeroentitydb.NewGormWrite(db)
+	ctx := context.Background()
+
+	if err := w.DeleteCtx(ctx, &account{}, 1); err != nil {
+		t.Fatalf("test DeleteCtx failed: %s", err.Error())
+	}
+	if err := w.MDeleteCtx(ctx, &account{}, 2, 3); err != nil {
+		t.Fatalf("test MDeleteCtx failed: %s", err.Error())
+	}
+	if err := w.MDeleteCtx(ctx, &account{}); err != nil {
+		t.Fatalf("test MDeleteCtx without ids failed: %s", err.Error())
+	}
+
+	if len(d.rows) != 1 || d.rows[4] != "zero4" {
+		t.Errorf("test MDeleteCtx failed, rows: %v", d.rows)
+	}
+}
@@ -1 +1,430 @@
 package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroutils "github.com/zerogo-hub/zero-helper/utils"
+)
+
+// defaultBatchSize MGet、MDelete 时每条 IN (...) 中默认的最大主键数量
+const defaultBatchSize = 500
+
+// SQLRow 一行数据，*sql.Row 与 *sql.Rows 均满足
+type SQLRow interface {
+	Scan(dest ...interface{}) error
+}
+
+// SQLScanner 将一行数据扫描到 out 中，返回该行的主键
+// out 为单个元素的指针，列的顺序与 SQLTable.Columns 一致
+type SQLScanner func(row SQLRow, out interface{}) (uint64, error)
+
+// SQLValuer 返回 in 的主键，以及按照 SQLTable.Columns 顺序排列的列值，用于 Update
+type SQLValuer func(in interface{}) (uint64, []interface{}, error)
+
+// SQLTable 表结构描述
+type SQLTable struct {
+	// Name 表名
+	Name string
+
+	// PrimaryKey 主键列名
+	PrimaryKey string
+
+	// Columns 需要查询与更新的列
+	Columns []string
+
+	// Scan 扫描一行数据，必须设置
+	Scan SQLScanner
+
+	// Values 获取列值，用于 Update，只读时可以不设置
+	Values SQLValuer
+
+	// BatchSize MGet、MDelete 时每条 IN (...) 中最多的主键数量，默认 500
+	BatchSize int
+
+	// Bindvar 第 i 个参数的占位符，i 从 1 开始，默认为 ?
+	// postgres 可以设置为 func(i int) string { return "$" + strconv.Itoa(i) }
+	Bindvar func(i int) string
+
+	// Upsert 数据不存在时插入的方言语句，参数为按照 Columns 顺序排列的列值，见 MySQLUpsert、PostgresUpsert
+	// 未设置时在事务中依次执行 UPDATE、SELECT、INSERT，并发插入同一主键时返回主键冲突的错误
+	Upsert string
+}
+
+// MySQLUpsert 生成 mysql 的 INSERT ... ON DUPLICATE KEY UPDATE 语句
+func MySQLUpsert(table *SQLTable) string {
+	sets := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		if column != table.PrimaryKey {
+			sets = append(sets, column+" = VALUES("+column+")")
+		}
+	}
+
+	return insertQuery(table) + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// PostgresUpsert 生成 postgres 与 sqlite 的 INSERT ... ON CONFLICT DO UPDATE 语句
+func PostgresUpsert(table *SQLTable) string {
+	sets := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		if column != table.PrimaryKey {
+			sets = append(sets, column+" = EXCLUDED."+column)
+		}
+	}
+
+	return insertQuery(table) + " ON CONFLICT (" + table.PrimaryKey + ") DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// insertQuery 插入所有列的语句
+func insertQuery(table *SQLTable) string {
+	binds := make([]string, 0, len(table.Columns))
+	for idx := range table.Columns {
+		binds = append(binds, bindvar(table, idx+1))
+	}
+
+	return "INSERT INTO " + table.Name + " (" + strings.Join(table.Columns, ", ") + ") VALUES (" + strings.Join(binds, ", ") + ")"
+}
+
+// wrapSQL 封装 database/sql
+type wrapSQL struct {
+	db          *sql.DB
+	table       *SQLTable
+	columns     string
+	errNotFound error
+}
+
+var _ zeroentity.IDScanner = (*wrapSQL)(nil)
+
+func newSQL(db *sql.DB, table *SQLTable) *wrapSQL {
+	return &wrapSQL{
+		db:          db,
+		table:       table,
+		columns:     strings.Join(table.Columns, ", "),
+		errNotFound: sql.ErrNoRows,
+	}
+}
+
+// NewSQLRead ..
+func NewSQLRead(db *sql.DB, table *SQLTable) zeroentity.WrapReadDB {
+	return newSQL(db, table)
+}
+
+// NewSQLReadF2 同 NewGormReadF2，数量会补齐为 2 的 n 次方
+func NewSQLReadF2(table *SQLTable, dbs ...*sql.DB) []zeroentity.WrapReadDB {
+	if len(dbs) == 0 {
+		return nil
+	}
+
+	if len(dbs) == 1 {
+		return []zeroentity.WrapReadDB{NewSQLRead(dbs[0], table)}
+	}
+
+	n := len(dbs)
+	f2n := zeroutils.F2(n)
+	results := make([]zeroentity.WrapReadDB, 0, f2n)
+
+	for _, db := range dbs {
+		results = append(results, NewSQLRead(db, table))
+	}
+
+	for len(results) < f2n {
+		for _, db := range dbs {
+			results = append(results, NewSQLRead(db, table))
+			if len(results) == f2n {
+				break
+			}
+		}
+	}
+
+	return results
+}
+
+// NewSQLWrite ..
+func NewSQLWrite(db *sql.DB, table *SQLTable) zeroentity.WrapWriteDB {
+	return newSQL(db, table)
+}
+
+func (w *wrapSQL) Get(out interface{}, id uint64) error {
+	return w.GetCtx(context.Background(), out, id)
+}
+
+func (w *wrapSQL) GetCtx(ctx context.Context, out interface{}, id uint64) error {
+	query := "SELECT " + w.columns + " FROM " + w.table.Name +
+		" WHERE " + w.table.PrimaryKey + " = " + w.bindvar(1) + " LIMIT 1"
+
+	_, err := w.table.Scan(w.db.QueryRowContext(ctx, query, id), out)
+	return err
+}
+
+func (w *wrapSQL) MGet(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	return w.MGetCtx(context.Background(), out, ids...)
+}
+
+// MGetCtx 批量查询，out 必须为切片指针，如 *[]Account 或 *[]*Account
+// ids 较多时按照 BatchSize 拆分为多条 IN (...) 查询
+func (w *wrapSQL) MGetCtx(ctx context.Context, out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	destValue := reflect.ValueOf(out)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return nil, nil, errors.New("out must be a pointer to slice")
+	}
+	sliceValue := destValue.Elem()
+
+	elemType := sliceValue.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+
+	primaryKeys := make([]uint64, 0, len(ids))
+	objects := make([]interface{}, 0, len(ids))
+
+	err := w.chunk(ids, func(part []uint64, in string, args []interface{}) error {
+		query := "SELECT " + w.columns + " FROM " + w.table.Name +
+			" WHERE " + w.table.PrimaryKey + " IN (" + in + ")"
+
+		rows, err := w.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			elem := reflect.New(elemType)
+			id, err := w.table.Scan(rows, elem.Interface())
+			if err != nil {
+				return err
+			}
+
+			if isPtr {
+				sliceValue.Set(reflect.Append(sliceValue, elem))
+				objects = append(objects, elem.Interface())
+			} else {
+				sliceValue.Set(reflect.Append(sliceValue, elem.Elem()))
+				objects = append(objects, elem.Elem().Interface())
+			}
+			primaryKeys = append(primaryKeys, id)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return primaryKeys, objects, nil
+}
+
+func (w *wrapSQL) ScanIDs(model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	return w.ScanIDsCtx(context.Background(), model, afterID, limit)
+}
+
+// ScanIDsCtx 按照主键升序扫描主键，model 未使用
+func (w *wrapSQL) ScanIDsCtx(ctx context.Context, model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	query := "SELECT " + w.table.PrimaryKey + " FROM " + w.table.Name +
+		" WHERE " + w.table.PrimaryKey + " > " + w.bindvar(1) +
+		" ORDER BY " + w.table.PrimaryKey + " LIMIT " + strconv.Itoa(limit)
+
+	rows, err := w.db.QueryContext(ctx, query, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]uint64, 0, limit)
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (w *wrapSQL) Update(in interface{}) error {
+	return w.UpdateCtx(context.Background(), in)
+}
+
+// UpdateCtx 更新，数据不存在时插入，见 SQLTable.Upsert
+func (w *wrapSQL) UpdateCtx(ctx context.Context, in interface{}) error {
+	if w.table.Values == nil {
+		return errors.New("table.Values cant be nil")
+	}
+
+	id, values, err := w.table.Values(in)
+	if err != nil {
+		return err
+	}
+	if len(values) != len(w.table.Columns) {
+		return errors.New("invalid length of values")
+	}
+
+	if w.table.Upsert != "" {
+		_, err := w.conn(ctx).ExecContext(ctx, w.table.Upsert, values...)
+		return err
+	}
+
+	sets := make([]string, 0, len(w.table.Columns))
+	args := make([]interface{}, 0, len(values)+1)
+	for idx, column := range w.table.Columns {
+		if column == w.table.PrimaryKey {
+			continue
+		}
+		args = append(args, values[idx])
+		sets = append(sets, column+" = "+w.bindvar(len(args)))
+	}
+	if len(sets) == 0 {
+		return errors.New("no columns to update except the primary key")
+	}
+	args = append(args, id)
+
+	query := "UPDATE " + w.table.Name + " SET " + strings.Join(sets, ", ") +
+		" WHERE " + w.table.PrimaryKey + " = " + w.bindvar(len(args))
+
+	return w.tx(ctx, func(conn sqlConn) error {
+		return w.upsert(ctx, conn, query, args, id, values)
+	})
+}
+
+// upsert 依次执行 UPDATE、SELECT、INSERT
+func (w *wrapSQL) upsert(ctx context.Context, conn sqlConn, query string, args []interface{}, id uint64, values []interface{}) error {
+	result, err := conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+
+	// 未修改任何行，可能是数据不存在，也可能是数据未变化 (mysql)
+	var exist int
+	query = "SELECT 1 FROM " + w.table.Name + " WHERE " + w.table.PrimaryKey + " = " + w.bindvar(1) + " LIMIT 1"
+	err = conn.QueryRowContext(ctx, query, id).Scan(&exist)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, insertQuery(w.table), values...)
+	return err
+}
+
+func (w *wrapSQL) Delete(model interface{}, id uint64) error {
+	return w.DeleteCtx(context.Background(), model, id)
+}
+
+func (w *wrapSQL) DeleteCtx(ctx context.Context, model interface{}, id uint64) error {
+	query := "DELETE FROM " + w.table.Name + " WHERE " + w.table.PrimaryKey + " = " + w.bindvar(1)
+	_, err := w.conn(ctx).ExecContext(ctx, query, id)
+	return err
+}
+
+func (w *wrapSQL) MDelete(model interface{}, ids ...uint64) error {
+	return w.MDeleteCtx(context.Background(), model, ids...)
+}
+
+// MDeleteCtx 超过 BatchSize 时分批删除，所有批次在同一个事务中执行
+func (w *wrapSQL) MDeleteCtx(ctx context.Context, model interface{}, ids ...uint64) error {
+	del := func(conn sqlConn) error {
+		return w.chunk(ids, func(part []uint64, in string, args []interface{}) error {
+			query := "DELETE FROM " + w.table.Name + " WHERE " + w.table.PrimaryKey + " IN (" + in + ")"
+			_, err := conn.ExecContext(ctx, query, args...)
+			return err
+		})
+	}
+
+	if len(ids) <= w.batchSize() {
+		return del(w.conn(ctx))
+	}
+	return w.tx(ctx, del)
+}
+
+func (w *wrapSQL) ErrNotFound() error {
+	return w.errNotFound
+}
+
+// sqlConn *sql.DB 与 *sql.Tx 均满足
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn 写入时使用的连接，ctx 中存在 SQLTransaction 开启的事务时使用该事务
+func (w *wrapSQL) conn(ctx context.Context) sqlConn {
+	if tx, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return w.db
+}
+
+// tx 在事务中执行 fn，ctx 中已经存在 SQLTransaction 开启的事务时直接使用该事务
+func (w *wrapSQL) tx(ctx context.Context, fn func(conn sqlConn) error) error {
+	if tx, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (w *wrapSQL) batchSize() int {
+	if w.table.BatchSize > 0 {
+		return w.table.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// chunk 按照 BatchSize 拆分 ids，生成 IN (...) 中的占位符与参数
+func (w *wrapSQL) chunk(ids []uint64, fn func(part []uint64, in string, args []interface{}) error) error {
+	size := w.batchSize()
+
+	for start := 0; start < len(ids); start += size {
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		part := ids[start:end]
+
+		binds := make([]string, 0, len(part))
+		args := make([]interface{}, 0, len(part))
+		for idx, id := range part {
+			binds = append(binds, w.bindvar(idx+1))
+			args = append(args, id)
+		}
+
+		if err := fn(part, strings.Join(binds, ", "), args); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *wrapSQL) bindvar(i int) string {
+	return bindvar(w.table, i)
+}
+
+func bindvar(table *SQLTable, i int) string {
+	if table.Bindvar != nil {
+		return table.Bindvar(i)
+	}
+	return "?"
+}
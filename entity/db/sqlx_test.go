@@ -0,0 +1,381 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zeroentitydb "github.com/zerogo-hub/zero-helper/entity/db"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+// fakeDriver 仅支持 account (id, name) 一张表的简易驱动
+type fakeDriver struct {
+	mu      sync.Mutex
+	rows    map[int64]string
+	queries []string
+}
+
+type fakeConn struct{ d *fakeDriver }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	idx     int
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{d: c.d}, nil }
+
+// fakeTx 不支持回滚数据，仅记录提交与回滚
+type fakeTx struct{ d *fakeDriver }
+
+func (tx *fakeTx) Commit() error {
+	tx.d.queries = append(tx.d.queries, "COMMIT")
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.d.queries = append(tx.d.queries, "ROLLBACK")
+	return nil
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, s.query)
+
+	var affected int64
+	switch {
+	case strings.HasPrefix(s.query, "UPDATE"):
+		id := args[1].(int64)
+		if _, ok := d.rows[id]; ok {
+			d.rows[id] = args[0].(string)
+			affected = 1
+		}
+	case strings.HasPrefix(s.query, "INSERT"):
+		d.rows[args[0].(int64)] = args[1].(string)
+		affected = 1
+	case strings.HasPrefix(s.query, "DELETE"):
+		for _, arg := range args {
+			if _, ok := d.rows[arg.(int64)]; ok {
+				delete(d.rows, arg.(int64))
+				affected++
+			}
+		}
+	}
+
+	return driver.RowsAffected(affected), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, s.query)
+
+	rows := &fakeRows{columns: []string{"id", "name"}}
+	if strings.HasPrefix(s.query, "SELECT id FROM") {
+		limit, _ := strconv.Atoi(s.query[strings.LastIndex(s.query, " ")+1:])
+		afterID := args[0].(int64)
+
+		ids := make([]int64, 0, len(d.rows))
+		for id := range d.rows {
+			if id > afterID {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		rows.columns = []string{"id"}
+		for idx, id := range ids {
+			if idx == limit {
+				break
+			}
+			rows.values = append(rows.values, []driver.Value{id})
+		}
+		return rows, nil
+	}
+	if strings.HasPrefix(s.query, "SELECT 1") {
+		rows.columns = []string{"1"}
+	}
+
+	for _, arg := range args {
+		id := arg.(int64)
+		name, ok := d.rows[id]
+		if !ok {
+			continue
+		}
+		if len(rows.columns) == 1 {
+			rows.values = append(rows.values, []driver.Value{int64(1)})
+		} else {
+			rows.values = append(rows.values, []driver.Value{id, name})
+		}
+	}
+
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.idx >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.idx])
+	r.idx++
+	return nil
+}
+
+type account struct {
+	ID   uint64
+	Name string
+}
+
+var accountTable = &zeroentitydb.SQLTable{
+	Name:       "account",
+	PrimaryKey: "id",
+	Columns:    []string{"id", "name"},
+	Scan: func(row zeroentitydb.SQLRow, out interface{}) (uint64, error) {
+		a := out.(*account)
+		err := row.Scan(&a.ID, &a.Name)
+		return a.ID, err
+	},
+	Values: func(in interface{}) (uint64, []interface{}, error) {
+		a := in.(*account)
+		return a.ID, []interface{}{a.ID, a.Name}, nil
+	},
+	BatchSize: 2,
+}
+
+var driverSeq = 0
+
+func openFake(t *testing.T) (*fakeDriver, *sql.DB) {
+	d := &fakeDriver{rows: map[int64]string{1: "zero1", 2: "zero2", 3: "zero3", 4: "zero4"}}
+
+	driverSeq++
+	name := "entityfake" + strconv.Itoa(driverSeq)
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open failed: %s", err.Error())
+	}
+	return d, db
+}
+
+func TestSQLGet(t *testing.T) {
+	_, db := openFake(t)
+	r := zeroentitydb.NewSQLRead(db, accountTable)
+
+	var a account
+	if err := r.Get(&a, 2); err != nil {
+		t.Fatalf("test Get failed: %s", err.Error())
+	}
+	if a.Name != "zero2" {
+		t.Errorf("test Get failed, name: %s", a.Name)
+	}
+
+	if err := r.Get(&a, 100); err != r.ErrNotFound() {
+		t.Errorf("test Get failed, err: %v", err)
+	}
+}
+
+func TestSQLMGet(t *testing.T) {
+	d, db := openFake(t)
+	r := zeroentitydb.NewSQLRead(db, accountTable)
+
+	var accounts []account
+	ids, datas, err := r.MGet(&accounts, 1, 2, 3, 4, 5)
+	if err != nil {
+		t.Fatalf("test MGet failed: %s", err.Error())
+	}
+	if len(ids) != 4 || len(datas) != 4 || len(accounts) != 4 {
+		t.Fatalf("test MGet failed, ids: %v", ids)
+	}
+	if datas[3].(account).Name != "zero4" {
+		t.Errorf("test MGet failed, datas: %v", datas)
+	}
+
+	// BatchSize 为 2，5 个主键拆分为 3 条查询
+	if len(d.queries) != 3 {
+		t.Errorf("test MGet failed, queries: %v", d.queries)
+	}
+}
+
+func TestSQLUpdate(t *testing.T) {
+	d, db := openFake(t)
+	w := zeroentitydb.NewSQLWrite(db, accountTable)
+
+	if err := w.Update(&account{ID: 1, Name: "one"}); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if d.rows[1] != "one" {
+		t.Errorf("test Update failed, name: %s", d.rows[1])
+	}
+
+	if err := w.Update(&account{ID: 10, Name: "ten"}); err != nil {
+		t.Fatalf("test Update with insert failed: %s", err.Error())
+	}
+	if d.rows[10] != "ten" {
+		t.Errorf("test Update with insert failed, rows: %v", d.rows)
+	}
+
+	if err := w.MDelete(nil, 1, 2, 10); err != nil {
+		t.Fatalf("test MDelete failed: %s", err.Error())
+	}
+	if len(d.rows) != 2 {
+		t.Errorf("test MDelete failed, rows: %v", d.rows)
+	}
+}
+
+func TestSQLUpsert(t *testing.T) {
+	d, db := openFake(t)
+
+	// 未设置 Upsert 时在事务中执行，分批删除同样在事务中执行
+	w := zeroentitydb.NewSQLWrite(db, accountTable)
+	if err := w.Update(&account{ID: 10, Name: "ten"}); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if err := w.MDelete(nil, 1, 2, 3); err != nil {
+		t.Fatalf("test MDelete failed: %s", err.Error())
+	}
+	if queries := strings.Join(d.queries, ";"); strings.Count(queries, "COMMIT") != 2 || d.rows[10] != "ten" {
+		t.Errorf("test Update in transaction failed, queries: %s", queries)
+	}
+
+	table := *accountTable
+	table.Upsert = zeroentitydb.MySQLUpsert(&table)
+	if table.Upsert != "INSERT INTO account (id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)" {
+		t.Errorf("test MySQLUpsert failed, query: %s", table.Upsert)
+	}
+
+	d.queries = nil
+	w = zeroentitydb.NewSQLWrite(db, &table)
+	if err := w.Update(&account{ID: 4, Name: "four"}); err != nil {
+		t.Fatalf("test Update with upsert failed: %s", err.Error())
+	}
+	if len(d.queries) != 1 || d.queries[0] != table.Upsert || d.rows[4] != "four" {
+		t.Errorf("test Update with upsert failed, queries: %v", d.queries)
+	}
+
+	table.Bindvar = func(i int) string { return "$" + strconv.Itoa(i) }
+	if query := zeroentitydb.PostgresUpsert(&table); query != "INSERT INTO account (id, name) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name" {
+		t.Errorf("test PostgresUpsert failed, query: %s", query)
+	}
+
+	// 除主键外没有其它列
+	table = *accountTable
+	table.Columns = []string{"id"}
+	table.Values = func(in interface{}) (uint64, []interface{}, error) {
+		return in.(*account).ID, []interface{}{in.(*account).ID}, nil
+	}
+	if err := zeroentitydb.NewSQLWrite(db, &table).Update(&account{ID: 5}); err == nil {
+		t.Error("test Update without columns failed, expected error")
+	}
+
+	// 设置了 Upsert 时只有主键也可以写入
+	table.Upsert = "REPLACE INTO account (id) VALUES (?)"
+	if err := zeroentitydb.NewSQLWrite(db, &table).Update(&account{ID: 5}); err != nil {
+		t.Errorf("test Update with upsert without columns failed: %s", err.Error())
+	}
+}
+
+func TestSQLScanIDs(t *testing.T) {
+	_, db := openFake(t)
+	r, ok := zeroentitydb.NewSQLRead(db, accountTable).(zeroentity.IDScanner)
+	if !ok {
+		t.Fatal("test ScanIDs failed, IDScanner not implemented")
+	}
+
+	ids, err := r.ScanIDs(nil, 1, 2)
+	if err != nil {
+		t.Fatalf("test ScanIDs failed: %s", err.Error())
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Errorf("test ScanIDs failed, ids: %v", ids)
+	}
+
+	ids, err = r.ScanIDs(nil, 3, 2)
+	if err != nil || len(ids) != 1 || ids[0] != 4 {
+		t.Errorf("test ScanIDs failed, ids: %v, err: %v", ids, err)
+	}
+}
+
+func TestSQLTransaction(t *testing.T) {
+	d, db := openFake(t)
+	local := zeroentitycache.NewBigCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(zeroentitydb.NewSQLRead(db, accountTable))
+	e.WithWriteDB(zeroentitydb.NewSQLWrite(db, accountTable))
+	e.WithLocalCache(local)
+	e.Build()
+	defer e.Close()
+
+	var a account
+	if err := e.Get(&a, 1); err != nil {
+		t.Fatalf("test Get failed: %s", err.Error())
+	}
+
+	errAbort := errors.New("abort")
+	err := zeroentitydb.SQLTransaction(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+		if err := e.UpdateCtx(ctx, &account{ID: 1, Name: "one"}, 1); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("test SQLTransaction rollback failed, err: %v", err)
+	}
+	if _, err := local.Get(1); err != nil {
+		t.Errorf("test SQLTransaction rollback failed, cache removed: %s", err.Error())
+	}
+
+	err = zeroentitydb.SQLTransaction(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+		if err := e.UpdateCtx(ctx, &account{ID: 1, Name: "one"}, 1); err != nil {
+			return err
+		}
+		if _, err := local.Get(1); err != nil {
+			t.Errorf("test SQLTransaction failed, cache removed before commit: %s", err.Error())
+		}
+		return e.MDeleteCtx(ctx, &account{}, 2, 3)
+	})
+	if err != nil {
+		t.Fatalf("test SQLTransaction failed: %s", err.Error())
+	}
+	if _, err := local.Get(1); err == nil {
+		t.Error("test SQLTransaction failed, cache not removed after commit")
+	}
+
+	queries := strings.Join(d.queries, ";")
+	if !strings.Contains(queries, "ROLLBACK") || !strings.HasSuffix(queries, "COMMIT") {
+		t.Errorf("test SQLTransaction failed, queries: %s", queries)
+	}
+}
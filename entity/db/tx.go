@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+
+	zerodatabase "github.com/zerogo-hub/zero-helper/database"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// gormTxKey 在 ctx 中存放 gorm 事务的键
+type gormTxKey struct{}
+
+// sqlTxKey 在 ctx 中存放 database/sql 事务的键
+type sqlTxKey struct{}
+
+// Transaction 在 gorm 事务中执行 fn，事务提交后才删除缓存
+//
+// fn 中使用传入的 ctx 调用 entity 的 UpdateCtx、DeleteCtx、MDeleteCtx，写入会使用同一个事务，
+// 缓存失效记录在 UnitOfWork 中，事务提交后执行，回滚时丢弃
+// 嵌套调用时使用 SavePoint，缓存统一在最外层事务提交后删除
+func Transaction(ctx context.Context, db zerodatabase.Database, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	if tx, ok := ctx.Value(gormTxKey{}).(*gorm.DB); ok {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, gormTxKey{}, tx), tx)
+		})
+	}
+
+	u := zeroentity.NewUnitOfWork()
+	ctx = zeroentity.ContextWithUnitOfWork(ctx, u)
+
+	err := db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, gormTxKey{}, tx), tx)
+	})
+	if err != nil {
+		u.Rollback()
+		return err
+	}
+
+	u.Commit(context.WithoutCancel(ctx))
+	return nil
+}
+
+// SQLTransaction 在 database/sql 事务中执行 fn，事务提交后才删除缓存，同 Transaction
+// 嵌套调用时直接加入外层事务
+func SQLTransaction(ctx context.Context, db *sql.DB, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	if tx, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return fn(ctx, tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	u := zeroentity.NewUnitOfWork()
+	txCtx := context.WithValue(zeroentity.ContextWithUnitOfWork(ctx, u), sqlTxKey{}, tx)
+
+	if err := fn(txCtx, tx); err != nil {
+		u.Rollback()
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.Rollback()
+		return err
+	}
+
+	u.Commit(context.WithoutCancel(ctx))
+	return nil
+}
@@ -2,9 +2,13 @@ package entity
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"math/rand"
+	"reflect"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/singleflight"
@@ -12,7 +16,9 @@ import (
 	zerobytes "github.com/zerogo-hub/zero-helper/bytes"
 	zerocodec "github.com/zerogo-hub/zero-helper/codec"
 	zerocollections "github.com/zerogo-hub/zero-helper/collections"
+	zerocompress "github.com/zerogo-hub/zero-helper/compress"
 	zerologger "github.com/zerogo-hub/zero-helper/logger"
+	zerorandom "github.com/zerogo-hub/zero-helper/random"
 	zerotimer "github.com/zerogo-hub/zero-helper/timer"
 	zeroutils "github.com/zerogo-hub/zero-helper/utils"
 )
@@ -33,6 +39,35 @@ type entity struct {
 	update UpdateHandler
 	delete DeleteHandler
 
+	// indexes 二级索引，索引名称 -> 索引
+	indexes map[string]*Index
+
+	// existence 存在性过滤器，防止缓存穿透
+	existence atomic.Pointer[existence]
+
+	// hot 热点主键探测
+	hot *hotKeys
+
+	// schema 数据结构版本
+	schema uint16
+	// versioned 是否设置了数据结构版本或者升级函数
+	versioned bool
+	// upgrades 缓存中数据的版本 -> 升级函数
+	upgrades map[uint16]UpgradeHandler
+
+	// compress 压缩缓存中的数据，长度不小于 compressMinSize 时压缩
+	compress        zerocompress.Compress
+	compressMinSize int
+
+	// breakers 与 readDBs 一一对应的熔断器
+	breakers    []*breaker
+	breakerConf *BreakerConfig
+
+	// writeMode 更新时的写入模式，默认更新数据库后删除缓存
+	writeMode WriteMode
+	// wb write-behind 等待写入数据库的数据
+	wb *writeBehind
+
 	// readDBsMatchF2 读数据库数量是否符合 2 的 n 次方，求余优化
 	readDBsMatchF2 bool
 
@@ -42,7 +77,7 @@ type entity struct {
 	// codec 编码解码，默认 msgpack
 	codec zerocodec.Codec
 
-	// twp 时间轮，定时器，用于缓存双删
+	// twp 时间轮，定时器，用于 write-behind、热点主键衰减
 	twp zerotimer.TimerWheelPool
 
 	// timeout 给 singleflight 设置一个超时时间
@@ -51,9 +86,32 @@ type entity struct {
 	// notFoundExpired 数据未找到时设置短期缓存的有效期，默认 1 分钟
 	notFoundExpired time.Duration
 
-	logger zerologger.Logger
-	g      singleflight.Group
-	gMulti singleflight.Group
+	// ttl 缓存有效期，0 表示使用缓存自身的默认过期时间
+	ttl time.Duration
+
+	// jitter 在有效期上增加 [0, jitter) 的随机时间，防止同一时刻写入的缓存同时过期
+	jitter time.Duration
+
+	// softTTL 软过期时间，超过后读取依旧返回缓存中的数据，同时在后台刷新，0 表示不开启
+	softTTL time.Duration
+
+	// invalidation 缓存失效策略，默认缓存双删
+	invalidation InvalidationStrategy
+
+	// hooks 数据更新、删除后的回调，如删除列表缓存，NewList 可能在使用中注册，由 hooksMu 保护
+	hooksMu sync.RWMutex
+	hooks   []InvalidateHook
+
+	// bus 广播缓存失效消息，通知其它实例删除本地缓存
+	bus      InvalidationTransport
+	busTopic string
+	// node 当前实例标识，忽略自己发出的失效消息
+	node string
+
+	logger   zerologger.Logger
+	g        singleflight.Group
+	gMulti   singleflight.Group
+	gRefresh singleflight.Group
 }
 
 // New 创建一个实体管理器
@@ -64,6 +122,8 @@ func New(st *Stat, logger zerologger.Logger, codec zerocodec.Codec) Entity {
 		twp:             *zerotimer.NewPool(16, 500*time.Millisecond, 120),
 		timeout:         500 * time.Millisecond,
 		notFoundExpired: 1 * time.Minute,
+		invalidation:    DoubleDelete(defaultDoubleDeleteDelay),
+		node:            zerorandom.LowerWithNumber(nodeSize),
 		logger:          logger,
 	}
 
@@ -76,173 +136,365 @@ func (e *entity) Build() {
 	if len(e.readDBs) == zeroutils.F2(len(e.readDBs)) {
 		e.readDBsMatchF2 = true
 	}
+
+	if e.wb != nil {
+		e.twp.AddTask(e.wb.conf.Interval, -1, func(t time.Time) {
+			_ = e.Flush(context.Background())
+		})
+	}
+
+	if e.breakerConf != nil {
+		e.breakers = make([]*breaker, 0, len(e.readDBs))
+		for range e.readDBs {
+			e.breakers = append(e.breakers, newBreaker(*e.breakerConf))
+		}
+
+		if e.st != nil {
+			e.st.setBreakers(e.BreakerStates)
+		}
+	}
+
+	if e.hot != nil {
+		e.twp.AddTask(e.hot.conf.Window, -1, func(t time.Time) {
+			e.hot.decay()
+		})
+
+		if e.st != nil {
+			e.st.setHotKeys(e.HotKeys)
+		}
+	}
+
+	if e.bus != nil {
+		if err := e.bus.Subscribe(e.busTopic, e.onInvalidation); err != nil {
+			e.logger.Errorf("failed to subscribe invalidation, topic: %s, err: %s", e.busTopic, err.Error())
+		}
+		if err := e.bus.Subscribe(e.busTopic+existTopicSuffix, e.onExist); err != nil {
+			e.logger.Errorf("failed to subscribe existence, topic: %s, err: %s", e.busTopic+existTopicSuffix, err.Error())
+		}
+	}
 }
 
 // Unmarshal 解码
 func (e *entity) Unmarshal(in []byte, out interface{}) error {
-	return e.codec.Unmarshal(in, out)
+	env, _ := unwrapEnvelope(in)
+
+	if err := e.uncompressPayload(env); err != nil {
+		return err
+	}
+
+	if e.mismatch(env) {
+		if handler, ok := e.upgrades[env.schema]; ok {
+			return handler(env.codec, env.payload, out)
+		}
+		return ErrSchemaMismatch
+	}
+
+	return e.codec.Unmarshal(env.payload, out)
+}
+
+// marshal 编码，需要元信息时封装为信封
+func (e *entity) marshal(in interface{}) ([]byte, error) {
+	bs, err := e.codec.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.softTTL <= 0 && !e.versioned && e.compress == nil {
+		return bs, nil
+	}
+
+	env := &envelope{
+		writeAt: time.Now().UnixMilli(),
+		schema:  e.schema,
+		codec:   e.codec.Name(),
+		payload: bs,
+	}
+	if err := e.compressPayload(env); err != nil {
+		return nil, err
+	}
+
+	return wrapEnvelope(env), nil
 }
 
 // Get 根据主键获取数据
 func (e *entity) Get(out interface{}, id uint64) error {
-	return e.get(out, id, nil)
+	return e.get(context.Background(), out, id, nil)
+}
+
+// GetCtx 根据主键获取数据
+func (e *entity) GetCtx(ctx context.Context, out interface{}, id uint64) error {
+	return e.get(ctx, out, id, nil)
 }
 
 // GetWithQuery 根据主键获取数据
 func (e *entity) GetWithQuery(out interface{}, id uint64, query QueryHandler) error {
-	return e.get(out, id, query)
+	return e.get(context.Background(), out, id, query)
 }
 
 // MGet 根据主键批量获取数据
 func (e *entity) MGet(out interface{}, ids ...uint64) (*Result, error) {
+	return e.MGetCtx(context.Background(), out, ids...)
+}
+
+// MGetCtx 根据主键批量获取数据
+//
+// 同一批 ids 的并发请求共享一次查询，查询使用首个请求的 ctx
+func (e *entity) MGetCtx(ctx context.Context, out interface{}, ids ...uint64) (*Result, error) {
+	return e.mgetCtx(ctx, out, ids, false)
+}
+
+// mgetPartial 根据主键批量获取数据，未找到的数据以占位符放入结果中，见 Typed
+func (e *entity) mgetPartial(ctx context.Context, out interface{}, ids ...uint64) (*Result, error) {
+	return e.mgetCtx(ctx, out, ids, true)
+}
+
+func (e *entity) mgetCtx(ctx context.Context, out interface{}, ids []uint64, partial bool) (*Result, error) {
 	if len(ids) == 0 {
 		return nil, ErrIDCantBeNull
 	}
 
+	e.recordRead(ids...)
+
 	key := genSingleFlightKeyMulti(ids...)
 
-	ch := e.gMulti.DoChan(key, func() (any, error) {
-		result := &Result{
-			Vals: make([][]byte, 0, len(ids)),
-			Errs: []error{},
-		}
+	// 两种模式的结果不同，不能共享
+	sfKey := key
+	if partial {
+		sfKey += ":partial"
+	}
 
-		missIds := make([]uint64, len(ids))
-		copy(missIds, ids)
+	ch := e.gMulti.DoChan(sfKey, func() (any, error) {
+		return e.mget(ctx, out, key, ids, partial, false)
+	})
 
-		if e.localCache != nil {
-			missIds = e.getMultiFromCache(e.localCache, result, ids...)
-			if len(missIds) == 0 {
-				// 全部命中缓存
-				if e.st != nil {
-					e.st.incLocalCacheHit()
-				}
-				return result, nil
-			}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(e.timeout):
+		if e.st != nil {
+			e.st.incTimeout()
+		}
+		return nil, ErrTimeout
+	case ret := <-ch:
+		if ret.Shared && e.st != nil {
+			e.st.incShared()
+		}
+		if ret.Err != nil {
+			return nil, ret.Err
+		}
 
-			// 存在未命中
-			if e.st != nil {
-				e.st.incLocalCacheMiss()
+		result := ret.Val.(*Result)
+		if len(result.Errs) > 0 {
+			for _, err := range result.Errs {
+				e.logger.Error(err.Error())
 			}
+			return nil, result.Errs[0]
 		}
 
-		if e.remoteCache != nil {
-			missIds = e.getMultiFromCache(e.remoteCache, result, missIds...)
-			if len(missIds) == 0 {
-				// 全部命中缓存
-				if e.st != nil {
-					e.st.incRemoteCacheHit()
-				}
-				return result, nil
-			}
+		return result, nil
+	}
+}
+
+// mget 依次从本地缓存、远端缓存、数据库或者自定义查找中批量获取数据，查找出来的结果会存入缓存中
+//
+// partial 为 false 时，存在未找到的数据则返回错误
+// partial 为 true 时，未找到的数据以短期缓存的占位符放入结果中
+// fillLocal 为 true 时，远端缓存命中的数据同时写入本地缓存，见 Preload
+func (e *entity) mget(ctx context.Context, out interface{}, key string, ids []uint64, partial, fillLocal bool) (*Result, error) {
+	result := &Result{
+		IDs:  make([]uint64, 0, len(ids)),
+		Vals: make([][]byte, 0, len(ids)),
+		Errs: []error{},
+	}
+
+	missIds := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if e.mayExist(id) {
+			missIds = append(missIds, id)
+			continue
+		}
+
+		// 一定不存在，与未命中而设置的短期缓存一样处理
+		result.IDs = append(result.IDs, id)
+		result.Vals = append(result.Vals, emptyPlaceholder)
+	}
+	if len(missIds) == 0 {
+		return result, nil
+	}
 
-			// 存在未命中
+	if e.localCache != nil {
+		start := time.Now()
+		missIds = e.getMultiFromCache(ctx, e.localCache, result, missIds...)
+		if e.st != nil {
+			e.st.observeLocal(start)
+		}
+		if len(missIds) == 0 {
+			// 全部命中缓存
 			if e.st != nil {
-				e.st.incRemoteCacheMiss()
+				e.st.incLocalCacheHit()
 			}
+			return result, nil
 		}
 
-		// 以下查找出来的结果会存入缓存中
-		if len(result.Errs) > 0 {
-			result.Errs = []error{}
+		// 存在未命中
+		if e.st != nil {
+			e.st.incLocalCacheMiss()
 		}
+	}
 
-		var loadedIDs []uint64
-		var loadedDatas []interface{}
-		var err error
-
-		if len(e.readDBs) > 0 {
-			loadedIDs, loadedDatas, err = e.readDBWithKey(key).MGet(out, missIds...)
-		} else if e.query != nil {
-			loadedIDs, loadedDatas, err = e.query(out, missIds...)
+	if e.remoteCache != nil {
+		start := time.Now()
+		n := len(result.IDs)
+		missIds = e.getMultiFromCache(ctx, e.remoteCache, result, missIds...)
+		if e.st != nil {
+			e.st.observeRemote(start)
+		}
+		if fillLocal {
+			e.fillLocalCache(ctx, result.IDs[n:], result.Vals[n:])
 		} else {
-			return nil, ErrNotFound
+			e.promote(ctx, result.IDs[n:], result.Vals[n:])
 		}
-
-		if err != nil {
-			return nil, err
+		if len(missIds) == 0 {
+			// 全部命中缓存
+			if e.st != nil {
+				e.st.incRemoteCacheHit()
+			}
+			return result, nil
 		}
 
-		if len(loadedIDs) != len(missIds) {
-			// 计算差集，将未搜索到的部分设计短期缓存
-			missIds = zerocollections.Difference(missIds, loadedIDs)
-			e.setMCacheWithNotFound(missIds...)
-			return nil, errors.New("some data not found")
+		// 存在未命中
+		if e.st != nil {
+			e.st.incRemoteCacheMiss()
 		}
+	}
 
-		loadedBytes := make([][]byte, 0, len(loadedIDs))
-		for _, data := range loadedDatas {
-			bs, err := e.codec.Marshal(data)
-			if err != nil {
-				return nil, err
-			}
-			loadedBytes = append(loadedBytes, bs)
-		}
+	// 以下查找出来的结果会存入缓存中
+	if len(result.Errs) > 0 {
+		result.Errs = []error{}
+	}
 
-		result.Vals = append(result.Vals, loadedBytes...)
+	var loadedIDs []uint64
+	var loadedDatas []interface{}
+	var err error
 
-		// 查找成功，写入缓存
-		e.msetCache(loadedIDs, loadedBytes)
+	start := time.Now()
+	if len(e.readDBs) > 0 {
+		err = e.callReadDB(ctx, zeroutils.ToUint64(key), func(db WrapReadDB) error {
+			loadedIDs, loadedDatas, err = db.MGetCtx(ctx, out, missIds...)
+			return err
+		})
+	} else if e.query != nil {
+		loadedIDs, loadedDatas, err = e.query(out, missIds...)
+	} else {
+		return nil, ErrNotFound
+	}
+	if e.st != nil {
+		e.st.observeDB(start)
+	}
 
-		return result, nil
-	})
+	if err != nil && !(partial && e.isNotFound(missIds[0], err)) {
+		return nil, err
+	}
 
-	select {
-	case <-time.After(e.timeout):
-		return nil, ErrTimeout
-	case ret := <-ch:
-		if ret.Err != nil {
-			return nil, ret.Err
+	loadedBytes := make([][]byte, 0, len(loadedIDs))
+	for _, data := range loadedDatas {
+		bs, err := e.marshal(data)
+		if err != nil {
+			return nil, err
 		}
+		loadedBytes = append(loadedBytes, bs)
+	}
 
-		result := ret.Val.(*Result)
-		if len(result.Errs) > 0 {
-			for _, err := range result.Errs {
-				e.logger.Error(err.Error())
-			}
-			return nil, result.Errs[0]
+	// 查找成功，写入缓存，加载期间被修改的数据除外
+	e.markExist(loadedIDs...)
+	cacheIDs, cacheBytes := e.freshLoaded(ctx, loadedIDs, loadedBytes, start)
+	e.msetCache(ctx, cacheIDs, cacheBytes)
+
+	result.IDs = append(result.IDs, loadedIDs...)
+	result.Vals = append(result.Vals, loadedBytes...)
+
+	if len(loadedIDs) != len(missIds) {
+		// 计算差集，将未搜索到的部分设计短期缓存
+		missIds = zerocollections.Difference(missIds, loadedIDs)
+		e.setMCacheWithNotFound(ctx, missIds...)
+
+		if !partial {
+			return nil, ErrSomeNotFound
 		}
 
-		return result, nil
+		for _, id := range missIds {
+			result.IDs = append(result.IDs, id)
+			result.Vals = append(result.Vals, emptyPlaceholder)
+		}
 	}
+
+	return result, nil
 }
 
 // Set 缓存数据
 func (e *entity) Set(in interface{}, id uint64) error {
-	bs, err := e.codec.Marshal(in)
+	bs, err := e.marshal(in)
 	if err != nil {
 		return err
 	}
 
-	e.setCache(id, bs)
+	e.announceExist(context.Background(), id)
+	e.setCache(context.Background(), id, bs)
 
 	return nil
 }
 
 // Update 更新
 func (e *entity) Update(model interface{}, id uint64) error {
-	if e.writeDB != nil {
-		if err := e.writeDB.Update(model); err != nil {
-			e.logger.Errorf("failed to update in db, id: %d, err: %s", id, err.Error())
+	return e.UpdateCtx(context.Background(), model, id)
+}
+
+// UpdateCtx 更新
+func (e *entity) UpdateCtx(ctx context.Context, model interface{}, id uint64) error {
+	// 写入成功后才标记存在，见 writeToDB、updateBehind
+	// 事务中，同步写入数据库，提交后再删除缓存
+	if u := UnitOfWorkFromContext(ctx); u != nil {
+		if err := e.writeToDB(ctx, model, id); err != nil {
 			return err
 		}
+		u.Add(e, model, id)
+		return nil
 	}
 
-	if e.update != nil {
-		if err := e.update(model, id); err != nil {
-			e.logger.Errorf("failed to update in e.update, id: %d, err: %s", id, err.Error())
+	switch e.writeMode {
+	case WriteModeBehind:
+		if err := e.updateBehind(ctx, model, id); err != nil {
+			return err
 		}
+	case WriteModeThrough:
+		if err := e.writeToDB(ctx, model, id); err != nil {
+			return err
+		}
+		e.writeThrough(ctx, model, id)
+	default:
+		if err := e.writeToDB(ctx, model, id); err != nil {
+			return err
+		}
+		e.invalidateCache(ctx, id)
 	}
 
-	e.doubleDeleteCache(id)
+	e.invalidateKeys(ctx, model)
+	e.runInvalidateHooks(ctx, model, id)
 
 	return nil
 }
 
 // Delete 删除
 func (e *entity) Delete(model interface{}, id uint64) error {
+	return e.DeleteCtx(context.Background(), model, id)
+}
+
+// DeleteCtx 删除
+func (e *entity) DeleteCtx(ctx context.Context, model interface{}, id uint64) error {
+	e.dropPending(id)
+
 	if e.writeDB != nil {
-		if err := e.writeDB.Delete(model, id); err != nil {
+		if err := e.writeDB.DeleteCtx(ctx, model, id); err != nil {
 			e.logger.Errorf("failed to delete in db, id: %d, err: %s", id, err.Error())
 			return err
 		}
@@ -254,15 +506,29 @@ func (e *entity) Delete(model interface{}, id uint64) error {
 		}
 	}
 
-	e.doubleDeleteCache(id)
+	if e.deferInvalidate(ctx, model, id) {
+		return nil
+	}
+
+	e.unmarkExist(id)
+	e.invalidateCache(ctx, id)
+	e.invalidateKeys(ctx, model)
+	e.runInvalidateHooks(ctx, model, id)
 
 	return nil
 }
 
 // MDelete 批量删除数据库，删除缓存
 func (e *entity) MDelete(model interface{}, ids ...uint64) error {
+	return e.MDeleteCtx(context.Background(), model, ids...)
+}
+
+// MDeleteCtx 批量删除数据库，删除缓存
+func (e *entity) MDeleteCtx(ctx context.Context, model interface{}, ids ...uint64) error {
+	e.dropPending(ids...)
+
 	if e.writeDB != nil {
-		if err := e.writeDB.MDelete(model, ids...); err != nil {
+		if err := e.writeDB.MDeleteCtx(ctx, model, ids...); err != nil {
 			e.logger.Errorf("failed to multi delete in db, id: %v, err: %s", ids, err.Error())
 			return err
 		}
@@ -274,7 +540,13 @@ func (e *entity) MDelete(model interface{}, ids ...uint64) error {
 		}
 	}
 
-	e.doubleMDeleteCache(ids...)
+	if e.deferInvalidate(ctx, nil, ids...) {
+		return nil
+	}
+
+	e.unmarkExist(ids...)
+	e.invalidateCache(ctx, ids...)
+	e.runInvalidateHooks(ctx, nil, ids...)
 
 	return nil
 }
@@ -294,6 +566,8 @@ func (e *entity) RemoveCache(id uint64) {
 			e.logger.Errorf("failed to delete in remote cache, id: %d, err: %s", id, err.Error())
 		}
 	}
+
+	e.publishInvalidation(context.Background(), id)
 }
 
 // WithCodec 设置编码解码器
@@ -314,6 +588,27 @@ func (e *entity) WithNotFoundExipred(expired time.Duration) Entity {
 	return e
 }
 
+// WithCacheTTL 设置缓存有效期
+func (e *entity) WithCacheTTL(ttl time.Duration) Entity {
+	e.ttl = ttl
+	return e
+}
+
+// WithJitter 设置有效期的随机抖动，同时作用于缓存有效期与短期缓存有效期
+func (e *entity) WithJitter(jitter time.Duration) Entity {
+	e.jitter = jitter
+	return e
+}
+
+// WithSoftTTL 开启 stale-while-revalidate
+//
+// 缓存中的数据写入超过 soft 后，Get 立即返回缓存中的数据，同时在后台刷新一次；
+// 刷新时查询失败，继续使用旧数据，直到缓存真正过期 (WithCacheTTL)
+func (e *entity) WithSoftTTL(soft time.Duration) Entity {
+	e.softTTL = soft
+	return e
+}
+
 func (e *entity) WithReadDB(dbs ...WrapReadDB) Entity {
 	if len(e.readDBs) == 0 {
 		e.readDBs = make([]WrapReadDB, 0, len(dbs))
@@ -352,16 +647,39 @@ func (e *entity) WithCustomDeleteHandler(handler DeleteHandler) Entity {
 	return e
 }
 
-func (e *entity) get(out interface{}, id uint64, query QueryHandler) error {
+// WithInvalidationBus 设置失效消息广播，删除缓存时通知其它实例删除本地缓存
+// 设置了存在性过滤器时，同时在频道 topic + ":exist" 广播新增的主键，见 WithExistenceFilter
+// topic 频道，不同实体应使用不同的频道
+func (e *entity) WithInvalidationBus(transport InvalidationTransport, topic string) Entity {
+	e.bus = transport
+	e.busTopic = topic
+	return e
+}
+
+// get 根据主键获取数据
+//
+// 同一主键的并发请求共享一次查询，查询使用首个请求的 ctx
+func (e *entity) get(ctx context.Context, out interface{}, id uint64, query QueryHandler) error {
+	// 一定不存在
+	if !e.mayExist(id) {
+		return ErrNotFound
+	}
+
+	e.recordRead(id)
+
 	key := genSingleFlightKey(id)
 
 	ch := e.g.DoChan(key, func() (interface{}, error) {
-		var err error
+		// 等待写入数据库的数据
+		if bs, ok := e.pendingBytes(id); ok {
+			return bs, nil
+		}
 
 		// 本地缓存
 		if e.localCache != nil {
-			bs, err := e.getFromLocalCache(id)
+			bs, err := e.getFromLocalCache(ctx, id)
 			if err == nil {
+				e.revalidate(id, bs, out, query)
 				return bs, nil
 			}
 			if err == ErrEmptyPlaceholder {
@@ -371,8 +689,10 @@ func (e *entity) get(out interface{}, id uint64, query QueryHandler) error {
 
 		// 远端缓存
 		if e.remoteCache != nil {
-			bs, err := e.getFromRemoteCache(id)
+			bs, err := e.getFromRemoteCache(ctx, id)
 			if err == nil {
+				e.promote(ctx, []uint64{id}, [][]byte{bs})
+				e.revalidate(id, bs, out, query)
 				return bs, nil
 			}
 			if err == ErrEmptyPlaceholder {
@@ -380,96 +700,178 @@ func (e *entity) get(out interface{}, id uint64, query QueryHandler) error {
 			}
 		}
 
-		// 以下查找出来的结果会存入缓存中
+		return e.load(ctx, out, id, query, false)
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(e.timeout):
+		if e.st != nil {
+			e.st.incTimeout()
+		}
+		return ErrTimeout
+	case ret := <-ch:
+		if ret.Shared && e.st != nil {
+			e.st.incShared()
+		}
+		if ret.Err != nil {
+			return ret.Err
+		}
+		return e.Unmarshal(ret.Val.([]byte), out)
+	}
+}
+
+// load 从数据库或者自定义查找中加载数据，查找出来的结果会存入缓存中
+//
+// keepStale 为 true 时，仅在数据确实不存在时才设置短期缓存，查询失败时保留缓存中的旧数据
+func (e *entity) load(ctx context.Context, out interface{}, id uint64, query QueryHandler, keepStale bool) ([]byte, error) {
+	var err error
 
-		if query != nil {
-			// 本次单独传入的自定义查找
-			_, _, err = query(out, id)
+	start := time.Now()
+	if query != nil {
+		// 本次单独传入的自定义查找
+		_, _, err = query(out, id)
 
-			if err != nil && e.st != nil {
-				e.st.incDBFail()
-			}
-		} else if len(e.readDBs) > 0 {
-			// 默认通过主键查找
-			err = e.readDB(id).Get(out, id)
+		if err != nil && e.st != nil {
+			e.st.incDBFail()
+		}
+	} else if len(e.readDBs) > 0 {
+		// 默认通过主键查找
+		err = e.callReadDB(ctx, id, func(db WrapReadDB) error {
+			return db.GetCtx(ctx, out, id)
+		})
 
-			if err != nil && e.st != nil {
-				e.st.incDBFail()
-			}
-		} else if e.query != nil {
-			// 全局自定义查找
-			_, _, err = e.query(out, id)
+		if err != nil && err != ErrCircuitOpen && e.st != nil {
+			e.st.incDBFail()
+		}
+	} else if e.query != nil {
+		// 全局自定义查找
+		_, _, err = e.query(out, id)
 
-			if err != nil && e.st != nil {
-				e.st.incCustomHandlerFail()
-			}
-		} else {
-			return nil, ErrNotFound
+		if err != nil && e.st != nil {
+			e.st.incCustomHandlerFail()
 		}
+	} else {
+		return nil, ErrNotFound
+	}
 
-		if err != nil {
-			// 数据未找到，设置短期缓存
-			e.setCacheWithNotFound(id)
+	if e.st != nil {
+		e.st.observeDB(start)
+	}
+
+	if err != nil {
+		// 调用方已放弃，不能认为数据不存在
+		if ctx.Err() != nil {
 			return nil, err
 		}
 
-		// 查找成功，写入缓存
-		bs, err := e.codec.Marshal(out)
-		if err != nil {
-			e.logger.Errorf("marshal failed, id: %d, err: %s", id, err.Error())
+		// 熔断中，快速失败，保留缓存中的旧数据
+		if err == ErrCircuitOpen {
+			if e.breakerConf.Placeholder && !keepStale {
+				e.setCacheWithNotFound(ctx, id)
+				return nil, ErrNotFound
+			}
 			return nil, err
 		}
-		e.setCache(id, bs)
 
+		if keepStale && !e.isNotFound(id, err) {
+			return nil, err
+		}
+
+		// 数据未找到，设置短期缓存
+		e.setCacheWithNotFound(ctx, id)
+		return nil, err
+	}
+
+	// 查找成功，写入缓存
+	bs, err := e.marshal(out)
+	if err != nil {
+		e.logger.Errorf("marshal failed, id: %d, err: %s", id, err.Error())
+		return nil, err
+	}
+	e.markExist(id)
+
+	// 加载期间数据被修改，不写回缓存
+	if e.loadedBefore(ctx, id, start) {
 		return bs, nil
-	})
+	}
+	e.setCache(ctx, id, bs)
 
-	select {
-	case <-time.After(e.timeout):
-		return ErrTimeout
-	case ret := <-ch:
-		if ret.Err != nil {
-			return ret.Err
+	return bs, nil
+}
+
+// revalidate 缓存中的数据超过 softTTL 时，在后台刷新一次
+//
+// 同一主键同一时间只会有一个刷新，刷新失败时保留旧数据
+func (e *entity) revalidate(id uint64, bs []byte, out interface{}, query QueryHandler) {
+	if e.softTTL <= 0 {
+		return
+	}
+
+	env, _ := unwrapEnvelope(bs)
+	if !env.isStale(e.softTTL) {
+		return
+	}
+
+	outType := reflect.TypeOf(out)
+	if outType.Kind() != reflect.Ptr {
+		return
+	}
+
+	// DoChan 在新的协程中执行，结果通道有缓冲，无需等待
+	e.gRefresh.DoChan(genSingleFlightKey(id), func() (interface{}, error) {
+		fresh := reflect.New(outType.Elem()).Interface()
+		bs, err := e.load(context.Background(), fresh, id, query, true)
+		if err != nil && !e.isNotFound(id, err) {
+			e.logger.Errorf("failed to refresh, id: %d, err: %s", id, err.Error())
 		}
-		return e.codec.Unmarshal(ret.Val.([]byte), out)
+		return bs, err
+	})
+}
+
+// isNotFound 查询错误是否表示数据不存在
+func (e *entity) isNotFound(id uint64, err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+
+	if db := e.readDB(id); db != nil && db.ErrNotFound() != nil {
+		return errors.Is(err, db.ErrNotFound())
 	}
+
+	return false
 }
 
 // readDB 获取一条数据库读配置
 //
 // 数据库读缓存，求余优化
 func (e *entity) readDB(id uint64) WrapReadDB {
-	count := len(e.readDBs)
-	if count == 1 {
-		return e.readDBs[0]
-	} else if count == 0 {
+	if len(e.readDBs) == 0 {
 		return nil
 	}
-
-	if e.readDBsMatchF2 {
-		return e.readDBs[id&uint64((len(e.readDBs))-1)]
-	}
-	return e.readDBs[id%uint64(len(e.readDBs))]
+	return e.readDBs[e.readDBIndex(id)]
 }
 
-func (e *entity) readDBWithKey(key string) WrapReadDB {
+// readDBIndex 读数据库的下标
+func (e *entity) readDBIndex(v uint64) int {
 	count := len(e.readDBs)
-	if count == 1 {
-		return e.readDBs[0]
-	} else if count == 0 {
-		return nil
+	if count <= 1 {
+		return 0
 	}
 
-	v := zeroutils.ToUint64(key)
-
 	if e.readDBsMatchF2 {
-		return e.readDBs[v&uint64((len(e.readDBs))-1)]
+		return int(v & uint64(count-1))
 	}
-	return e.readDBs[v%uint64(len(e.readDBs))]
+	return int(v % uint64(count))
 }
 
-func (e *entity) getFromLocalCache(id uint64) ([]byte, error) {
-	data, err := e.localCache.Get(id)
+func (e *entity) getFromLocalCache(ctx context.Context, id uint64) ([]byte, error) {
+	if e.st != nil {
+		defer e.st.observeLocal(time.Now())
+	}
+
+	data, err := e.localCache.GetCtx(ctx, id)
 	if err != nil {
 		if e.st != nil {
 			e.st.incLocalCacheMiss()
@@ -484,6 +886,14 @@ func (e *entity) getFromLocalCache(id uint64) ([]byte, error) {
 		return nil, e.localCache.ErrNotFound()
 	}
 
+	// 版本或者编码不一致，视为未命中
+	if !e.compatible(data) {
+		if e.st != nil {
+			e.st.incLocalCacheMiss()
+		}
+		return nil, ErrSchemaMismatch
+	}
+
 	// 从缓存中找到数据
 	if e.st != nil {
 		e.st.incLocalCacheHit()
@@ -497,8 +907,12 @@ func (e *entity) getFromLocalCache(id uint64) ([]byte, error) {
 	return data, nil
 }
 
-func (e *entity) getFromRemoteCache(id uint64) ([]byte, error) {
-	data, err := e.remoteCache.Get(id)
+func (e *entity) getFromRemoteCache(ctx context.Context, id uint64) ([]byte, error) {
+	if e.st != nil {
+		defer e.st.observeRemote(time.Now())
+	}
+
+	data, err := e.remoteCache.GetCtx(ctx, id)
 	if err != nil {
 		if e.st != nil {
 			e.st.incRemoteCacheMiss()
@@ -513,6 +927,14 @@ func (e *entity) getFromRemoteCache(id uint64) ([]byte, error) {
 		return nil, e.remoteCache.ErrNotFound()
 	}
 
+	// 版本或者编码不一致，视为未命中
+	if !e.compatible(data) {
+		if e.st != nil {
+			e.st.incRemoteCacheMiss()
+		}
+		return nil, ErrSchemaMismatch
+	}
+
 	// 从缓存中找到数据
 	if e.st != nil {
 		e.st.incRemoteCacheHit()
@@ -525,35 +947,25 @@ func (e *entity) getFromRemoteCache(id uint64) ([]byte, error) {
 	return data, nil
 }
 
-func (e *entity) setCacheWithNotFound(id uint64) {
+func (e *entity) setCacheWithNotFound(ctx context.Context, id uint64) {
+	ttl := e.expire(e.notFoundExpired)
+
 	if e.localCache != nil {
-		if err := e.localCache.Set(id, emptyPlaceholder); err != nil {
+		if err := e.localCache.SetExCtx(ctx, id, emptyPlaceholder, ttl); err != nil {
 			e.logger.Errorf("set local cache with not found failed, id: %d, err: %s", id, err.Error())
 			return
 		}
-
-		e.twp.AddTask(e.notFoundExpired, 1, func(t time.Time) {
-			if err := e.localCache.Delete(id); err != nil {
-				e.logger.Errorf("failed to delay delete in local cache, id: %d, err: %s", id, err.Error())
-			}
-		})
 	}
 
 	if e.remoteCache != nil {
-		if err := e.remoteCache.Set(id, emptyPlaceholder); err != nil {
+		if err := e.remoteCache.SetExCtx(ctx, id, emptyPlaceholder, ttl); err != nil {
 			e.logger.Errorf("set remote cache with not found failed, id: %d, err: %s", id, err.Error())
 			return
 		}
-
-		e.twp.AddTask(e.notFoundExpired, 1, func(t time.Time) {
-			if err := e.remoteCache.Delete(id); err != nil {
-				e.logger.Errorf("failed to delay delete in remote cache, id: %d, err: %s", id, err.Error())
-			}
-		})
 	}
 }
 
-func (e *entity) setMCacheWithNotFound(ids ...uint64) {
+func (e *entity) setMCacheWithNotFound(ctx context.Context, ids ...uint64) {
 	if len(ids) == 0 {
 		return
 	}
@@ -561,115 +973,133 @@ func (e *entity) setMCacheWithNotFound(ids ...uint64) {
 	for i := 0; i < len(ids); i++ {
 		datas = append(datas, emptyPlaceholder)
 	}
+	ttls := e.expires(e.notFoundExpired, len(ids))
 
 	if e.localCache != nil {
-		if err := e.localCache.MSet(ids, datas); err != nil {
+		if err := e.localCache.MSetExCtx(ctx, ids, datas, ttls); err != nil {
 			e.logger.Errorf("set local multi cache with not found failed, id: %v, err: %s", ids, err.Error())
 			return
 		}
-
-		e.twp.AddTask(e.notFoundExpired, 1, func(t time.Time) {
-			if err := e.localCache.MDelete(ids...); err != nil {
-				e.logger.Errorf("failed to delay multi delete in local cache, ids: %v, err: %s", ids, err.Error())
-			}
-		})
 	}
 
 	if e.remoteCache != nil {
-		if err := e.remoteCache.MSet(ids, datas); err != nil {
+		if err := e.remoteCache.MSetExCtx(ctx, ids, datas, ttls); err != nil {
 			e.logger.Errorf("set remote multi cache with not found failed, id: %v, err: %s", ids, err.Error())
 			return
 		}
-
-		e.twp.AddTask(e.notFoundExpired, 1, func(t time.Time) {
-			if err := e.remoteCache.MDelete(ids...); err != nil {
-				e.logger.Errorf("failed to delay multi delete in remote cache, ids: %v, err: %s", ids, err.Error())
-			}
-		})
 	}
 }
 
-func (e *entity) setCache(id uint64, bs []byte) {
-	if e.localCache != nil {
-		if err := e.localCache.Set(id, bs); err != nil {
+func (e *entity) setCache(ctx context.Context, id uint64, bs []byte) {
+	ttl := e.expire(e.ttl)
+
+	if e.localCache != nil && e.keepLocal(id) {
+		if err := e.localCache.SetExCtx(ctx, id, bs, ttl); err != nil {
 			e.logger.Errorf("set local cache failed, id: %d, err: %s", id, err.Error())
 		}
 	}
 
 	if e.remoteCache != nil {
-		if err := e.remoteCache.Set(id, bs); err != nil {
+		if err := e.remoteCache.SetExCtx(ctx, id, bs, ttl); err != nil {
 			e.logger.Errorf("set remote cache failed, id: %d, err: %s", id, err.Error())
 		}
 	}
 }
 
-func (e *entity) msetCache(ids []uint64, datas [][]byte) {
+func (e *entity) msetCache(ctx context.Context, ids []uint64, datas [][]byte) {
+	ttls := e.expires(e.ttl, len(ids))
+
 	if e.localCache != nil {
-		if err := e.localCache.MSet(ids, datas); err != nil {
-			e.logger.Errorf("set local cache failed, ids: %v, err: %s", ids, err.Error())
+		localIDs, localDatas, localTTLs := ids, datas, ttls
+		if e.hot != nil && e.hot.conf.Promote {
+			localIDs, localDatas, localTTLs = nil, nil, nil
+			for idx, id := range ids {
+				if e.keepLocal(id) {
+					localIDs = append(localIDs, id)
+					localDatas = append(localDatas, datas[idx])
+					localTTLs = append(localTTLs, ttls[idx])
+				}
+			}
+		}
+
+		if len(localIDs) > 0 {
+			if err := e.localCache.MSetExCtx(ctx, localIDs, localDatas, localTTLs); err != nil {
+				e.logger.Errorf("set local cache failed, ids: %v, err: %s", localIDs, err.Error())
+			}
 		}
 	}
 
 	if e.remoteCache != nil {
-		if err := e.remoteCache.MSet(ids, datas); err != nil {
+		if err := e.remoteCache.MSetExCtx(ctx, ids, datas, ttls); err != nil {
 			e.logger.Errorf("set remote cache failed, id: %v, err: %s", ids, err.Error())
 		}
 	}
 }
 
-// doubleDeleteCache 缓存双删
-func (e *entity) doubleDeleteCache(id uint64) {
-	if e.localCache != nil {
-		// 立即从缓存中删除
-		if err := e.localCache.Delete(id); err != nil && err != e.localCache.ErrNotFound() {
-			e.logger.Errorf("failed to delete in local cache, id: %d, err: %s", id, err.Error())
-		}
-		// 延迟从缓存中删除
-		e.twp.AddTask(2*time.Second, 1, func(t time.Time) {
-			if err := e.localCache.Delete(id); err != nil && err != e.localCache.ErrNotFound() {
-				e.logger.Errorf("failed to delay delete in local cache, id: %d, err: %s", id, err.Error())
-			}
-		})
+// expire 在有效期上增加随机抖动，base <= 0 时表示使用缓存默认过期时间
+func (e *entity) expire(base time.Duration) time.Duration {
+	if base <= 0 || e.jitter <= 0 {
+		return base
 	}
+	return base + time.Duration(rand.Int63n(int64(e.jitter)))
+}
 
-	if e.remoteCache != nil {
-		// 立即从缓存中删除
-		if err := e.remoteCache.Delete(id); err != nil && err != e.remoteCache.ErrNotFound() {
-			e.logger.Errorf("failed to delete in remote cache, id: %d, err: %s", id, err.Error())
-		}
-		// 延迟从缓存中删除
-		e.twp.AddTask(2*time.Second, 1, func(t time.Time) {
-			if err := e.remoteCache.Delete(id); err != nil && err != e.remoteCache.ErrNotFound() {
-				e.logger.Errorf("failed to delay delete in remote cache, id: %d, err: %s", id, err.Error())
-			}
-		})
+// expires 为每个主键单独计算有效期
+func (e *entity) expires(base time.Duration, n int) []time.Duration {
+	ttls := make([]time.Duration, n)
+	for idx := range ttls {
+		ttls[idx] = e.expire(base)
 	}
+	return ttls
 }
 
-func (e *entity) doubleMDeleteCache(ids ...uint64) {
-	if e.localCache != nil {
-		if err := e.localCache.MDelete(ids...); err != nil {
-			e.logger.Errorf("failed to multi delete in local cache, ids: %v, err: %s", ids, err.Error())
-		}
+// writeThrough 更新数据库后写入缓存，并通知其它实例删除本地缓存
+func (e *entity) writeThrough(ctx context.Context, model interface{}, id uint64) {
+	bs, err := e.marshal(model)
+	if err != nil {
+		e.logger.Errorf("marshal failed, id: %d, err: %s", id, err.Error())
+		e.invalidateCache(ctx, id)
+		return
+	}
 
-		e.twp.AddTask(2*time.Second, 1, func(t time.Time) {
-			if err := e.localCache.MDelete(ids...); err != nil {
-				e.logger.Errorf("failed to delay delete delete in local cache, ids: %v, err: %s", ids, err.Error())
-			}
-		})
+	e.setCache(ctx, id, bs)
+	e.publishInvalidation(ctx, id)
+}
+
+// publishInvalidation 通知其它实例删除本地缓存
+func (e *entity) publishInvalidation(ctx context.Context, ids ...uint64) {
+	if e.bus == nil || len(ids) == 0 {
+		return
 	}
 
-	if e.remoteCache != nil {
-		if err := e.remoteCache.MDelete(ids...); err != nil {
-			e.logger.Errorf("failed to multi delete in remote cache, ids: %v, err: %s", ids, err.Error())
-		}
+	if err := e.bus.Publish(ctx, e.busTopic, encodeInvalidation(e.node, ids...)); err != nil {
+		e.logger.Errorf("failed to publish invalidation, ids: %v, err: %s", ids, err.Error())
+	}
+}
 
-		e.twp.AddTask(2*time.Second, 1, func(t time.Time) {
-			if err := e.remoteCache.MDelete(ids...); err != nil {
-				e.logger.Errorf("failed to delay delete delete in remote cache, ids: %v, err: %s", ids, err.Error())
-			}
-		})
+// onInvalidation 收到其它实例的失效消息，删除本地缓存
+//
+// 远端缓存由发送方删除，这里只处理本地缓存，同样按照失效策略处理
+func (e *entity) onInvalidation(msg []byte) {
+	node, ids, err := decodeInvalidation(msg)
+	if err != nil {
+		e.logger.Errorf("failed to decode invalidation, err: %s", err.Error())
+		return
 	}
+
+	if node == e.node || e.localCache == nil || len(ids) == 0 {
+		return
+	}
+
+	target := cacheTarget{e: e, localOnly: true}
+
+	// 由 binlog 驱动时，消息来自调用 RemoveCache 的实例，直接删除
+	if _, ok := e.invalidation.(binlogDriven); ok {
+		target.Delete(context.Background(), ids...)
+		return
+	}
+
+	e.invalidation.Invalidate(context.Background(), target, ids...)
 }
 
 func genSingleFlightKey(id uint64) string {
@@ -692,15 +1122,22 @@ func genSingleFlightKeyMulti(ids ...uint64) string {
 	return buf.String()
 }
 
-func (e *entity) getMultiFromCache(cache WrapCache, result *Result, ids ...uint64) []uint64 {
-	vals, _ := cache.MGet(ids...)
+func (e *entity) getMultiFromCache(ctx context.Context, cache WrapCache, result *Result, ids ...uint64) []uint64 {
+	vals, err := cache.MGetCtx(ctx, ids...)
+	if err != nil {
+		// 缓存不可用时全部视为未命中，继续从下一级读取
+		e.logger.Errorf("failed to mget from cache, ids: %v, err: %s", ids, err.Error())
+		return ids
+	}
 
 	missIds := []uint64{}
 	for _, v := range vals {
-		if v.Err == nil && len(v.Val) > 0 {
+		if v.Err == nil && len(v.Val) > 0 && e.compatible(v.Val) {
+			result.IDs = append(result.IDs, v.ID)
 			result.Vals = append(result.Vals, v.Val)
 		} else {
-			if v.Err != nil {
+			// 单项未找到只是未命中，不作为错误返回
+			if v.Err != nil && !errors.Is(v.Err, cache.ErrNotFound()) {
 				result.Errs = append(result.Errs, v.Err)
 			}
 
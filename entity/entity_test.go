@@ -0,0 +1,93 @@
+package entity_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestGetCtxDeadline(t *testing.T) {
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithTimeout(time.Second)
+	e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return queryAccounts(out, ids...)
+	})
+	e.Build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var a account
+	err := e.GetCtx(ctx, &a, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("test GetCtx failed, err: %v", err)
+	}
+}
+
+func TestSoftTTL(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		version int
+		fail    bool
+	)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithSoftTTL(50 * time.Millisecond)
+	e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if fail {
+			return nil, nil, errors.New("db is down")
+		}
+
+		version++
+		a := account{UUID: ids[0], Username: "v" + strconv.Itoa(version)}
+		*out.(*account) = a
+		return ids, []interface{}{a}, nil
+	})
+	e.Build()
+
+	get := func() string {
+		var a account
+		if err := e.Get(&a, 1); err != nil {
+			t.Fatalf("test Get failed: %s", err.Error())
+		}
+		return a.Username
+	}
+
+	if name := get(); name != "v1" {
+		t.Fatalf("test Get failed, name: %s", name)
+	}
+
+	// 超过软过期时间，立即返回旧数据，后台刷新
+	time.Sleep(80 * time.Millisecond)
+	if name := get(); name != "v1" {
+		t.Errorf("test stale Get failed, name: %s", name)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if name := get(); name != "v2" {
+		t.Errorf("test refreshed Get failed, name: %s", name)
+	}
+
+	// 刷新失败时保留旧数据
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	time.Sleep(80 * time.Millisecond)
+	get()
+	time.Sleep(20 * time.Millisecond)
+	if name := get(); name != "v2" {
+		t.Errorf("test stale if error failed, name: %s", name)
+	}
+}
@@ -0,0 +1,212 @@
+package entitytest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// ErrCacheMiss 内存缓存中数据不存在，由 Cache.ErrNotFound 返回
+var ErrCacheMiss = errors.New("entitytest: cache miss")
+
+// cacheItem 缓存的数据，deadline 为零值表示不过期
+type cacheItem struct {
+	data     []byte
+	deadline time.Time
+}
+
+// Cache 内存缓存，实现 zeroentity.WrapCache，并发安全
+type Cache struct {
+	Faults
+
+	ttl time.Duration
+
+	mu    sync.Mutex
+	items map[uint64]cacheItem
+}
+
+var _ zeroentity.WrapCache = (*Cache)(nil)
+
+// NewCache 创建一个内存缓存
+//
+// ttl 默认过期时间，0 表示不过期
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, items: make(map[uint64]cacheItem)}
+}
+
+// Len 未过期的数据数量
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	now := time.Now()
+	for _, item := range c.items {
+		if item.deadline.IsZero() || now.Before(item.deadline) {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Cache) Get(id uint64) ([]byte, error) {
+	return c.GetCtx(context.Background(), id)
+}
+
+func (c *Cache) GetCtx(ctx context.Context, id uint64) ([]byte, error) {
+	if err := c.inject(ctx, "Get"); err != nil {
+		return nil, err
+	}
+
+	return c.get(id)
+}
+
+func (c *Cache) MGet(ids ...uint64) ([]*zeroentity.Value, error) {
+	return c.MGetCtx(context.Background(), ids...)
+}
+
+// MGetCtx 结果与 ids 一一对应，不存在的数据 Err 为 ErrCacheMiss
+func (c *Cache) MGetCtx(ctx context.Context, ids ...uint64) ([]*zeroentity.Value, error) {
+	if err := c.inject(ctx, "MGet"); err != nil {
+		return nil, err
+	}
+
+	results := make([]*zeroentity.Value, 0, len(ids))
+	for _, id := range ids {
+		val, err := c.get(id)
+		results = append(results, &zeroentity.Value{ID: id, Val: val, Err: err})
+	}
+
+	return results, nil
+}
+
+func (c *Cache) Set(id uint64, in []byte) error {
+	return c.SetExCtx(context.Background(), id, in, 0)
+}
+
+func (c *Cache) SetCtx(ctx context.Context, id uint64, in []byte) error {
+	return c.SetExCtx(ctx, id, in, 0)
+}
+
+func (c *Cache) SetExCtx(ctx context.Context, id uint64, in []byte, ttl time.Duration) error {
+	if err := c.inject(ctx, "Set"); err != nil {
+		return err
+	}
+
+	c.set(id, in, ttl)
+	return nil
+}
+
+func (c *Cache) MSet(ids []uint64, datas [][]byte) error {
+	return c.MSetExCtx(context.Background(), ids, datas, nil)
+}
+
+func (c *Cache) MSetCtx(ctx context.Context, ids []uint64, datas [][]byte) error {
+	return c.MSetExCtx(ctx, ids, datas, nil)
+}
+
+func (c *Cache) MSetExCtx(ctx context.Context, ids []uint64, datas [][]byte, ttls []time.Duration) error {
+	if len(ids) != len(datas) || (ttls != nil && len(ids) != len(ttls)) {
+		return errors.New("invalid length")
+	}
+
+	if err := c.inject(ctx, "MSet"); err != nil {
+		return err
+	}
+
+	for idx, id := range ids {
+		var ttl time.Duration
+		if ttls != nil {
+			ttl = ttls[idx]
+		}
+		c.set(id, datas[idx], ttl)
+	}
+
+	return nil
+}
+
+func (c *Cache) Delete(id uint64) error {
+	return c.DeleteCtx(context.Background(), id)
+}
+
+// DeleteCtx 数据不存在时返回 ErrCacheMiss
+func (c *Cache) DeleteCtx(ctx context.Context, id uint64) error {
+	if err := c.inject(ctx, "Delete"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[id]; !ok {
+		return ErrCacheMiss
+	}
+	delete(c.items, id)
+
+	return nil
+}
+
+func (c *Cache) MDelete(ids ...uint64) error {
+	return c.MDeleteCtx(context.Background(), ids...)
+}
+
+// MDeleteCtx 数据不存在时忽略
+func (c *Cache) MDeleteCtx(ctx context.Context, ids ...uint64) error {
+	if err := c.inject(ctx, "MDelete"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		delete(c.items, id)
+	}
+
+	return nil
+}
+
+func (c *Cache) ErrNotFound() error {
+	return ErrCacheMiss
+}
+
+// get 读取未过期且未被设置为不存在的数据，返回副本
+func (c *Cache) get(id uint64) ([]byte, error) {
+	if c.hidden(id) {
+		return nil, ErrCacheMiss
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[id]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	if !item.deadline.IsZero() && !time.Now().Before(item.deadline) {
+		delete(c.items, id)
+		return nil, ErrCacheMiss
+	}
+
+	return append([]byte(nil), item.data...), nil
+}
+
+// set 保存副本，ttl <= 0 时使用默认过期时间
+func (c *Cache) set(id uint64, in []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	item := cacheItem{data: append([]byte(nil), in...)}
+	if ttl > 0 {
+		item.deadline = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[id] = item
+}
@@ -0,0 +1,340 @@
+package entitytest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// Row 一致性测试使用的数据结构
+type Row struct {
+	ID   uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Name string
+}
+
+// Rows 执行 ReadDBConformance、WriteDBConformance 之前，数据库中必须存在且仅存在这些数据
+var Rows = []Row{
+	{ID: 1, Name: "zero1"},
+	{ID: 2, Name: "zero2"},
+	{ID: 3, Name: "zero3"},
+}
+
+// CacheConformance 校验 WrapCache 的实现是否满足接口约定
+//
+// newCache 每个子测试调用一次，返回一个空的缓存，默认过期时间不能小于 1 分钟
+// 数据不存在时，Get 返回的错误以及 MGet 结果中的 Err 必须为 ErrNotFound()
+func CacheConformance(t *testing.T, newCache func() zeroentity.WrapCache) {
+	ctx := context.Background()
+
+	t.Run("GetMiss", func(t *testing.T) {
+		c := newCache()
+		if _, err := c.Get(1001); !errors.Is(err, c.ErrNotFound()) {
+			t.Errorf("Get on missing id, err: %v, want ErrNotFound(): %v", err, c.ErrNotFound())
+		}
+	})
+
+	t.Run("SetGet", func(t *testing.T) {
+		c := newCache()
+		if err := c.Set(1001, []byte("hello")); err != nil {
+			t.Fatalf("Set failed: %s", err.Error())
+		}
+		if bs, err := c.Get(1001); err != nil || !bytes.Equal(bs, []byte("hello")) {
+			t.Errorf("Get after Set, val: %q, err: %v", bs, err)
+		}
+
+		if err := c.SetCtx(ctx, 1001, []byte("world")); err != nil {
+			t.Fatalf("SetCtx failed: %s", err.Error())
+		}
+		if bs, err := c.GetCtx(ctx, 1001); err != nil || !bytes.Equal(bs, []byte("world")) {
+			t.Errorf("GetCtx after overwrite, val: %q, err: %v", bs, err)
+		}
+	})
+
+	t.Run("MGet", func(t *testing.T) {
+		c := newCache()
+		if err := c.MSet([]uint64{1001, 1002}, [][]byte{[]byte("a"), []byte("b")}); err != nil {
+			t.Fatalf("MSet failed: %s", err.Error())
+		}
+
+		values, err := c.MGetCtx(ctx, 1002, 1003, 1001)
+		if err != nil {
+			t.Fatalf("MGetCtx failed: %s", err.Error())
+		}
+		if len(values) != 3 {
+			t.Fatalf("MGetCtx returned %d values, want 3", len(values))
+		}
+
+		want := []struct {
+			id  uint64
+			val string
+			hit bool
+		}{{1002, "b", true}, {1003, "", false}, {1001, "a", true}}
+		for idx, w := range want {
+			v := values[idx]
+			if v.ID != w.id {
+				t.Errorf("MGetCtx value %d, id: %d, want: %d", idx, v.ID, w.id)
+			}
+			if w.hit && (v.Err != nil || string(v.Val) != w.val) {
+				t.Errorf("MGetCtx value %d, val: %q, err: %v, want: %q", idx, v.Val, v.Err, w.val)
+			}
+			if !w.hit && !errors.Is(v.Err, c.ErrNotFound()) {
+				t.Errorf("MGetCtx missing value %d, err: %v, want ErrNotFound()", idx, v.Err)
+			}
+		}
+
+		if values, err := c.MGet(); err != nil || len(values) != 0 {
+			t.Errorf("MGet without ids, values: %v, err: %v", values, err)
+		}
+	})
+
+	t.Run("MSetInvalidLength", func(t *testing.T) {
+		c := newCache()
+		if err := c.MSetCtx(ctx, []uint64{1001, 1002}, [][]byte{[]byte("a")}); err == nil {
+			t.Error("MSetCtx with mismatched datas should fail")
+		}
+		if err := c.MSetExCtx(ctx, []uint64{1001}, [][]byte{[]byte("a")}, []time.Duration{0, 0}); err == nil {
+			t.Error("MSetExCtx with mismatched ttls should fail")
+		}
+		if err := c.MSetExCtx(ctx, nil, nil, nil); err != nil {
+			t.Errorf("MSetExCtx without ids, err: %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := newCache()
+		c.MSet([]uint64{1001, 1002, 1003}, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+		if err := c.Delete(1001); err != nil {
+			t.Errorf("Delete failed: %s", err.Error())
+		}
+		if _, err := c.Get(1001); !errors.Is(err, c.ErrNotFound()) {
+			t.Errorf("Get after Delete, err: %v, want ErrNotFound()", err)
+		}
+
+		// 删除不存在的数据，只允许返回 nil 或者 ErrNotFound()
+		if err := c.DeleteCtx(ctx, 1001); err != nil && !errors.Is(err, c.ErrNotFound()) {
+			t.Errorf("DeleteCtx on missing id, err: %v", err)
+		}
+
+		if err := c.MDeleteCtx(ctx, 1002, 1003, 1004); err != nil && !errors.Is(err, c.ErrNotFound()) {
+			t.Errorf("MDeleteCtx failed: %s", err.Error())
+		}
+		for _, id := range []uint64{1002, 1003} {
+			if _, err := c.Get(id); !errors.Is(err, c.ErrNotFound()) {
+				t.Errorf("Get %d after MDeleteCtx, err: %v, want ErrNotFound()", id, err)
+			}
+		}
+
+		if err := c.MDelete(); err != nil {
+			t.Errorf("MDelete without ids, err: %v", err)
+		}
+	})
+
+	t.Run("SetEx", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping ttl test in short mode")
+		}
+		t.Parallel()
+
+		// 部分实现的过期时间精度为秒
+		c := newCache()
+		if err := c.SetExCtx(ctx, 1001, []byte("a"), time.Second); err != nil {
+			t.Fatalf("SetExCtx failed: %s", err.Error())
+		}
+		if err := c.MSetExCtx(ctx, []uint64{1002, 1003}, [][]byte{[]byte("b"), []byte("c")}, []time.Duration{time.Second, 0}); err != nil {
+			t.Fatalf("MSetExCtx failed: %s", err.Error())
+		}
+		if _, err := c.Get(1001); err != nil {
+			t.Errorf("Get before expired, err: %v", err)
+		}
+
+		time.Sleep(2100 * time.Millisecond)
+
+		for _, id := range []uint64{1001, 1002} {
+			if _, err := c.Get(id); !errors.Is(err, c.ErrNotFound()) {
+				t.Errorf("Get %d after expired, err: %v, want ErrNotFound()", id, err)
+			}
+		}
+
+		// ttl 为 0 时使用默认过期时间
+		if bs, err := c.Get(1003); err != nil || string(bs) != "c" {
+			t.Errorf("Get with default ttl, val: %q, err: %v", bs, err)
+		}
+	})
+}
+
+// ReadDBConformance 校验 WrapReadDB 的实现是否满足接口约定，db 中必须已经写入 Rows
+//
+// 数据不存在时，Get 返回的错误必须为 ErrNotFound() 或者 zeroentity.ErrNotFound，
+// MGet 忽略不存在的数据
+func ReadDBConformance(t *testing.T, db zeroentity.WrapReadDB) {
+	ctx := context.Background()
+
+	t.Run("Get", func(t *testing.T) {
+		for _, row := range Rows {
+			var out Row
+			if err := db.GetCtx(ctx, &out, row.ID); err != nil {
+				t.Fatalf("GetCtx %d failed: %s", row.ID, err.Error())
+			}
+			if out != row {
+				t.Errorf("GetCtx %d, row: %+v, want: %+v", row.ID, out, row)
+			}
+		}
+	})
+
+	t.Run("GetMiss", func(t *testing.T) {
+		var out Row
+		if err := db.Get(&out, 100); !isNotFound(db, err) {
+			t.Errorf("Get on missing id, err: %v, want ErrNotFound()", err)
+		}
+	})
+
+	t.Run("GetCanceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		var out Row
+		if err := db.GetCtx(canceled, &out, 1); err == nil {
+			t.Error("GetCtx with canceled context should fail")
+		}
+	})
+
+	t.Run("MGet", func(t *testing.T) {
+		var outs []Row
+		ids, objects, err := db.MGetCtx(ctx, &outs, 3, 100, 1)
+		if err != nil {
+			t.Fatalf("MGetCtx failed: %s", err.Error())
+		}
+		if len(ids) != 2 || len(objects) != 2 || len(outs) != 2 {
+			t.Fatalf("MGetCtx, ids: %v, objects: %d, outs: %d, want 2 of each", ids, len(objects), len(outs))
+		}
+
+		for idx, id := range ids {
+			if id != 1 && id != 3 {
+				t.Errorf("MGetCtx returned unexpected id: %d", id)
+			}
+			if rowID(objects[idx]) != id {
+				t.Errorf("MGetCtx object %d does not match id %d: %+v", idx, id, objects[idx])
+			}
+		}
+
+		outs = nil
+		ids, _, err = db.MGet(&outs, 100, 101)
+		if err != nil && !isNotFound(db, err) {
+			t.Errorf("MGet on missing ids, err: %v", err)
+		}
+		if len(ids) != 0 {
+			t.Errorf("MGet on missing ids, ids: %v", ids)
+		}
+	})
+
+	t.Run("ScanIDs", func(t *testing.T) {
+		scanner, ok := db.(zeroentity.IDScanner)
+		if !ok {
+			t.Skip("db does not implement IDScanner")
+		}
+
+		want := [][]uint64{{1, 2}, {3}, {}}
+		afterID := uint64(0)
+		for idx, w := range want {
+			ids, err := scanner.ScanIDsCtx(ctx, &Row{}, afterID, 2)
+			if err != nil {
+				t.Fatalf("ScanIDsCtx failed: %s", err.Error())
+			}
+			if !equalIDs(ids, w) {
+				t.Fatalf("ScanIDsCtx batch %d, ids: %v, want: %v", idx, ids, w)
+			}
+			if len(ids) > 0 {
+				afterID = ids[len(ids)-1]
+			}
+		}
+	})
+}
+
+// WriteDBConformance 校验 WrapWriteDB 的实现是否满足接口约定，会修改数据
+//
+// w 与 r 指向同一个数据库，且已经写入 Rows，Update 在数据不存在时插入
+func WriteDBConformance(t *testing.T, w zeroentity.WrapWriteDB, r zeroentity.WrapReadDB) {
+	ctx := context.Background()
+
+	t.Run("Update", func(t *testing.T) {
+		if err := w.UpdateCtx(ctx, &Row{ID: 2, Name: "two"}); err != nil {
+			t.Fatalf("UpdateCtx failed: %s", err.Error())
+		}
+
+		var out Row
+		if err := r.GetCtx(ctx, &out, 2); err != nil || out.Name != "two" {
+			t.Errorf("GetCtx after UpdateCtx, row: %+v, err: %v", out, err)
+		}
+
+		if err := w.Update(&Row{ID: 4, Name: "four"}); err != nil {
+			t.Fatalf("Update new row failed: %s", err.Error())
+		}
+		if err := r.GetCtx(ctx, &out, 4); err != nil || out.Name != "four" {
+			t.Errorf("GetCtx after inserting, row: %+v, err: %v", out, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := w.DeleteCtx(ctx, &Row{}, 2); err != nil {
+			t.Fatalf("DeleteCtx failed: %s", err.Error())
+		}
+
+		var out Row
+		if err := r.GetCtx(ctx, &out, 2); !isNotFound(r, err) {
+			t.Errorf("GetCtx after DeleteCtx, err: %v, want ErrNotFound()", err)
+		}
+
+		// 删除不存在的数据，只允许返回 nil 或者 ErrNotFound()
+		if err := w.Delete(&Row{}, 2); err != nil && !errors.Is(err, w.ErrNotFound()) {
+			t.Errorf("Delete on missing id, err: %v", err)
+		}
+	})
+
+	t.Run("MDelete", func(t *testing.T) {
+		if err := w.MDeleteCtx(ctx, &Row{}, 1, 3, 100); err != nil && !errors.Is(err, w.ErrNotFound()) {
+			t.Fatalf("MDeleteCtx failed: %s", err.Error())
+		}
+
+		for _, id := range []uint64{1, 3} {
+			var out Row
+			if err := r.GetCtx(ctx, &out, id); !isNotFound(r, err) {
+				t.Errorf("GetCtx %d after MDeleteCtx, err: %v, want ErrNotFound()", id, err)
+			}
+		}
+	})
+}
+
+func isNotFound(db zeroentity.WrapReadDB, err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, zeroentity.ErrNotFound) || (db.ErrNotFound() != nil && errors.Is(err, db.ErrNotFound()))
+}
+
+// rowID MGet 返回的数据可以是 Row 或者 *Row
+func rowID(object interface{}) uint64 {
+	switch row := object.(type) {
+	case Row:
+		return row.ID
+	case *Row:
+		return row.ID
+	}
+	return 0
+}
+
+func equalIDs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
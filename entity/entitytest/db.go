@@ -0,0 +1,219 @@
+package entitytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+)
+
+// ErrRecordNotFound 内存数据库中数据不存在，由 DB.ErrNotFound 返回
+var ErrRecordNotFound = errors.New("entitytest: record not found")
+
+// DB 内存数据库，实现 zeroentity.WrapReadDB 与 zeroentity.WrapWriteDB，并发安全
+//
+// 保存的是结构体的副本 (浅拷贝)，Get 时赋值给 out
+type DB struct {
+	Faults
+
+	key func(in interface{}) uint64
+
+	mu   sync.RWMutex
+	rows map[uint64]interface{}
+}
+
+var (
+	_ zeroentity.WrapReadDB  = (*DB)(nil)
+	_ zeroentity.IDScanner   = (*DB)(nil)
+	_ zeroentity.WrapWriteDB = (*DB)(nil)
+)
+
+// NewDB 创建一个内存数据库
+//
+// key 从 Update 传入的数据中取出主键
+func NewDB(key func(in interface{}) uint64) *DB {
+	return &DB{key: key, rows: make(map[uint64]interface{})}
+}
+
+// Put 直接写入数据，不计入调用次数，也不会注入故障，用于准备测试数据
+func (db *DB) Put(id uint64, in interface{}) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.rows[id] = reflect.Indirect(reflect.ValueOf(in)).Interface()
+}
+
+// Row 直接读取数据，不计入调用次数，也不会注入故障
+func (db *DB) Row(id uint64) (interface{}, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	row, ok := db.rows[id]
+	return row, ok
+}
+
+// Len 数据数量
+func (db *DB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return len(db.rows)
+}
+
+func (db *DB) Get(out interface{}, id uint64) error {
+	return db.GetCtx(context.Background(), out, id)
+}
+
+func (db *DB) GetCtx(ctx context.Context, out interface{}, id uint64) error {
+	if err := db.inject(ctx, "Get"); err != nil {
+		return err
+	}
+
+	dest := reflect.ValueOf(out)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() {
+		return errors.New("entitytest: out must be a non-nil pointer")
+	}
+
+	row, ok := db.row(id)
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	value := reflect.ValueOf(row)
+	if !value.Type().AssignableTo(dest.Elem().Type()) {
+		return fmt.Errorf("entitytest: cannot assign %s to %s", value.Type(), dest.Elem().Type())
+	}
+	dest.Elem().Set(value)
+
+	return nil
+}
+
+func (db *DB) MGet(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	return db.MGetCtx(context.Background(), out, ids...)
+}
+
+// MGetCtx out 为切片指针，如 *[]Account、*[]*Account，数据不存在时忽略，与 gorm 的 Find 一致
+func (db *DB) MGetCtx(ctx context.Context, out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	if err := db.inject(ctx, "MGet"); err != nil {
+		return nil, nil, err
+	}
+
+	dest := reflect.ValueOf(out)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() || dest.Elem().Kind() != reflect.Slice {
+		return nil, nil, errors.New("entitytest: out must be a pointer to slice")
+	}
+
+	sliceType := dest.Elem().Type()
+	elemType := sliceType.Elem()
+	slice := reflect.MakeSlice(sliceType, 0, len(ids))
+
+	loadedIDs := make([]uint64, 0, len(ids))
+	objects := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		row, ok := db.row(id)
+		if !ok {
+			continue
+		}
+
+		elem := reflect.ValueOf(row)
+		if elemType.Kind() == reflect.Ptr {
+			p := reflect.New(elemType.Elem())
+			p.Elem().Set(elem)
+			elem = p
+		}
+		if !elem.Type().AssignableTo(elemType) {
+			return nil, nil, fmt.Errorf("entitytest: cannot assign %s to %s", elem.Type(), elemType)
+		}
+
+		slice = reflect.Append(slice, elem)
+		loadedIDs = append(loadedIDs, id)
+		objects = append(objects, elem.Interface())
+	}
+	dest.Elem().Set(slice)
+
+	return loadedIDs, objects, nil
+}
+
+func (db *DB) ScanIDs(model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	return db.ScanIDsCtx(context.Background(), model, afterID, limit)
+}
+
+func (db *DB) ScanIDsCtx(ctx context.Context, model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	if err := db.inject(ctx, "ScanIDs"); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	ids := make([]uint64, 0, len(db.rows))
+	for id := range db.rows {
+		if id > afterID && !db.hidden(id) {
+			ids = append(ids, id)
+		}
+	}
+	db.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	return ids, nil
+}
+
+func (db *DB) Update(in interface{}) error {
+	return db.UpdateCtx(context.Background(), in)
+}
+
+// UpdateCtx 数据不存在时插入，与 gorm 的 Save 一致
+func (db *DB) UpdateCtx(ctx context.Context, in interface{}) error {
+	if err := db.inject(ctx, "Update"); err != nil {
+		return err
+	}
+
+	db.Put(db.key(in), in)
+	return nil
+}
+
+func (db *DB) Delete(model interface{}, id uint64) error {
+	return db.DeleteCtx(context.Background(), model, id)
+}
+
+// DeleteCtx 数据不存在时不返回错误
+func (db *DB) DeleteCtx(ctx context.Context, model interface{}, id uint64) error {
+	return db.MDeleteCtx(ctx, model, id)
+}
+
+func (db *DB) MDelete(model interface{}, ids ...uint64) error {
+	return db.MDeleteCtx(context.Background(), model, ids...)
+}
+
+func (db *DB) MDeleteCtx(ctx context.Context, model interface{}, ids ...uint64) error {
+	if err := db.inject(ctx, "Delete"); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, id := range ids {
+		delete(db.rows, id)
+	}
+
+	return nil
+}
+
+func (db *DB) ErrNotFound() error {
+	return ErrRecordNotFound
+}
+
+// row 读取未被设置为不存在的数据
+func (db *DB) row(id uint64) (interface{}, bool) {
+	if db.hidden(id) {
+		return nil, false
+	}
+	return db.Row(id)
+}
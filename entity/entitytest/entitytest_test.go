@@ -0,0 +1,151 @@
+package entitytest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	"github.com/zerogo-hub/zero-helper/entity/entitytest"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func newDB() *entitytest.DB {
+	db := entitytest.NewDB(func(in interface{}) uint64 { return in.(*entitytest.Row).ID })
+	for _, row := range entitytest.Rows {
+		db.Put(row.ID, row)
+	}
+	return db
+}
+
+func TestCacheConformance(t *testing.T) {
+	entitytest.CacheConformance(t, func() zeroentity.WrapCache {
+		return entitytest.NewCache(time.Minute)
+	})
+}
+
+func TestDBConformance(t *testing.T) {
+	entitytest.ReadDBConformance(t, newDB())
+
+	db := newDB()
+	entitytest.WriteDBConformance(t, db, db)
+}
+
+func TestFaults(t *testing.T) {
+	db := newDB()
+	errDown := errors.New("db down")
+
+	db.FailWith(errDown, 1)
+	var out entitytest.Row
+	if err := db.Get(&out, 1); err != errDown {
+		t.Errorf("test FailWith failed, err: %v", err)
+	}
+	if err := db.Get(&out, 1); err != nil {
+		t.Errorf("test FailWith failed, expected recovery, err: %v", err)
+	}
+
+	db.SetNotFound(2)
+	if err := db.Get(&out, 2); err != db.ErrNotFound() {
+		t.Errorf("test SetNotFound failed, err: %v", err)
+	}
+
+	db.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := db.GetCtx(ctx, &out, 1); err != context.DeadlineExceeded {
+		t.Errorf("test SetLatency failed, err: %v", err)
+	}
+
+	db.Reset()
+	if err := db.Get(&out, 2); err != nil {
+		t.Errorf("test Reset failed, err: %v", err)
+	}
+	if n := db.Calls("Get"); n != 5 {
+		t.Errorf("test Calls failed, calls: %d", n)
+	}
+}
+
+func TestEntityWithFakes(t *testing.T) {
+	db := newDB()
+	cache := entitytest.NewCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(db)
+	e.WithWriteDB(db)
+	e.WithLocalCache(cache)
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[entitytest.Row](e)
+
+	for i := 0; i < 2; i++ {
+		if row, err := typed.Get(1); err != nil || row.Name != "zero1" {
+			t.Fatalf("test Get failed, row: %+v, err: %v", row, err)
+		}
+	}
+	if n := db.Calls("Get"); n != 1 {
+		t.Errorf("test Get failed, db calls: %d", n)
+	}
+
+	rows, err := typed.MGet(1, 2, 3)
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("test MGet failed, rows: %+v, err: %v", rows, err)
+	}
+
+	// 缓存故障时从数据库中读取
+	cache.FailWith(errors.New("cache down"), -1)
+	if row, err := typed.Get(2); err != nil || row.Name != "zero2" {
+		t.Errorf("test Get with cache down failed, row: %+v, err: %v", row, err)
+	}
+	n := db.Calls("MGet")
+	if rows, err := typed.MGet(1, 2); err != nil || len(rows) != 2 || db.Calls("MGet") != n+1 {
+		t.Errorf("test MGet with cache down failed, rows: %+v, db calls: %d, err: %v", rows, db.Calls("MGet")-n, err)
+	}
+	cache.Reset()
+
+	if err := typed.Update(&entitytest.Row{ID: 3, Name: "three"}, 3); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if row, err := typed.Get(3); err != nil || row.Name != "three" {
+		t.Errorf("test Get after Update failed, row: %+v, err: %v", row, err)
+	}
+
+	if err := typed.Delete(3); err != nil {
+		t.Fatalf("test Delete failed: %s", err.Error())
+	}
+	if _, err := typed.Get(3); err == nil {
+		t.Error("test Get after Delete failed, expected error")
+	}
+}
+
+func TestMGetLocalMissRemoteHit(t *testing.T) {
+	db := newDB()
+	local, remote := entitytest.NewCache(time.Minute), entitytest.NewCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(db)
+	e.WithLocalCache(local)
+	e.WithRemoteCache(remote)
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[entitytest.Row](e)
+	if _, err := typed.MGet(1, 2); err != nil {
+		t.Fatalf("test MGet failed: %s", err.Error())
+	}
+
+	// 本地缓存未命中，远端缓存命中
+	local.MDelete(1, 2)
+	n := db.Calls("MGet")
+	rows, err := typed.MGet(1, 2)
+	if err != nil || len(rows) != 2 || db.Calls("MGet") != n {
+		t.Errorf("test MGet with local miss failed, rows: %+v, db calls: %d, err: %v", rows, db.Calls("MGet")-n, err)
+	}
+
+	local.MDelete(1, 2)
+	if m, err := typed.MGetMap(1, 2); err != nil || len(m) != 2 {
+		t.Errorf("test MGetMap with local miss failed, rows: %+v, err: %v", m, err)
+	}
+}
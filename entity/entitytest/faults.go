@@ -0,0 +1,115 @@
+// Package entitytest 实体的测试工具
+//
+// 提供内存实现的 WrapReadDB、WrapWriteDB、WrapCache，支持故障注入 (延迟、错误、数据不存在)，
+// 以及第三方实现可以使用的一致性测试
+package entitytest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Faults 故障注入，并发安全，零值表示无故障
+type Faults struct {
+	mu       sync.Mutex
+	latency  time.Duration
+	err      error
+	failN    int
+	notFound map[uint64]struct{}
+	calls    map[string]int
+}
+
+// SetLatency 每次调用前等待 d，期间 ctx 结束时返回 ctx.Err()
+func (f *Faults) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.latency = d
+}
+
+// FailWith 之后的 n 次调用返回 err，n < 0 表示一直失败，err 为 nil 表示取消
+func (f *Faults) FailWith(err error, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.err = err
+	f.failN = n
+}
+
+// SetNotFound 这些主键视为不存在，即使已经写入
+func (f *Faults) SetNotFound(ids ...uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.notFound == nil {
+		f.notFound = make(map[uint64]struct{})
+	}
+	for _, id := range ids {
+		f.notFound[id] = struct{}{}
+	}
+}
+
+// Reset 清除所有故障，不清除调用次数
+func (f *Faults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.latency = 0
+	f.err = nil
+	f.failN = 0
+	f.notFound = nil
+}
+
+// Calls 方法被调用的次数，如 "Get"、"MGet"，带 Ctx 与不带 Ctx 的方法计入同一个名称
+func (f *Faults) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[method]
+}
+
+// inject 记录调用，并按照设置注入延迟与错误
+func (f *Faults) inject(ctx context.Context, method string) error {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[method]++
+
+	latency := f.latency
+	var err error
+	if f.err != nil && f.failN != 0 {
+		err = f.err
+		if f.failN > 0 {
+			f.failN--
+		}
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// hidden 主键是否被设置为不存在
+func (f *Faults) hidden(id uint64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.notFound[id]
+	return ok
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// 缓存值的信封格式，仅在开启需要元信息的功能 (如 WithSoftTTL、WithSchemaVersion) 时使用
+//
+// [0:2]   魔数 0x00 'z'，protobuf、msgpack 结构体、json 编码结果均不会以 0x00 开头
+// [2]     信封格式版本
+// [3]     标识位
+// [4:12]  写入时间，unix 毫秒
+// [12:14] 数据结构版本
+// [14]    编码名称长度 n
+// [15:15+n] 编码名称，如 msgpack
+// [15+n:] 编码后的数据
+const (
+	envelopeMagic0     = 0x00
+	envelopeMagic1     = 'z'
+	envelopeVersion    = 2
+	envelopeHeaderSize = 12
+)
+
+// envelope 缓存值及其元信息
+type envelope struct {
+	flags   byte
+	writeAt int64
+	// schema 数据结构版本，旧数据为 0
+	schema uint16
+	// codec 编码名称，旧数据为空
+	codec   string
+	payload []byte
+}
+
+// wrapEnvelope 将数据封装为信封
+func wrapEnvelope(env *envelope) []byte {
+	codec := env.codec
+	if len(codec) > 255 {
+		codec = codec[:255]
+	}
+
+	size := envelopeHeaderSize + 3 + len(codec)
+	out := make([]byte, size+len(env.payload))
+	out[0] = envelopeMagic0
+	out[1] = envelopeMagic1
+	out[2] = envelopeVersion
+	out[3] = env.flags
+	binary.BigEndian.PutUint64(out[4:], uint64(env.writeAt))
+	binary.BigEndian.PutUint16(out[12:], env.schema)
+	out[14] = byte(len(codec))
+	copy(out[15:], codec)
+	copy(out[size:], env.payload)
+	return out
+}
+
+// unwrapEnvelope 解析信封，不是信封格式时 (旧数据) 原样返回，ok 为 false
+func unwrapEnvelope(in []byte) (env *envelope, ok bool) {
+	if len(in) < envelopeHeaderSize || in[0] != envelopeMagic0 || in[1] != envelopeMagic1 {
+		return &envelope{payload: in}, false
+	}
+
+	if in[2] != envelopeVersion || len(in) < envelopeHeaderSize+3 {
+		return &envelope{payload: in}, false
+	}
+	size := envelopeHeaderSize + 3 + int(in[14])
+	if len(in) < size {
+		return &envelope{payload: in}, false
+	}
+
+	return &envelope{
+		flags:   in[3],
+		writeAt: int64(binary.BigEndian.Uint64(in[4:])),
+		schema:  binary.BigEndian.Uint16(in[12:]),
+		codec:   string(in[15:size]),
+		payload: in[size:],
+	}, true
+}
+
+// isStale 写入时间超过 soft 即认为数据陈旧，旧数据没有写入时间，视为陈旧
+func (env *envelope) isStale(soft time.Duration) bool {
+	return time.Since(time.UnixMilli(env.writeAt)) > soft
+}
@@ -69,6 +69,9 @@ func main() {
 
 	// 测试批量操作
 	testMulti(db, e, logger)
+
+	// 测试泛型
+	testTyped(e, logger)
 }
 
 func testReady(database zerodatabase.Database) {
@@ -200,3 +203,23 @@ func testMultiQuery(e zeroentity.Entity, logger zerologger.Logger) {
 		}
 	}
 }
+
+func testTyped(e zeroentity.Entity, logger zerologger.Logger) {
+	typed := zeroentity.NewTyped[Account](e)
+
+	account, err := typed.Get(1)
+	if err != nil {
+		logger.Errorf("[testTyped] Get failed, err: %s", err.Error())
+		return
+	}
+	logger.Infof("[testTyped] Get, UUID: %d, Username: %s", account.UUID, account.Username)
+
+	accounts, err := typed.MGetMap(11, 12)
+	if err != nil {
+		logger.Errorf("[testTyped] MGetMap failed, err: %s", err.Error())
+		return
+	}
+	for id, account := range accounts {
+		logger.Infof("[testTyped] MGetMap, id: %d, Username: %s", id, account.Username)
+	}
+}
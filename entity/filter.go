@@ -0,0 +1,201 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	zerobytes "github.com/zerogo-hub/zero-helper/bytes"
+)
+
+var (
+	// ErrNoReadDB 未设置读数据库
+	ErrNoReadDB = errors.New("no read db")
+	// ErrNoIDScanner 读数据库未实现 IDScanner
+	ErrNoIDScanner = errors.New("read db does not implement IDScanner")
+)
+
+const (
+	// scanBatchSize 重建过滤器时每次从数据库中扫描的主键数量
+	scanBatchSize = 1000
+
+	// existTopicSuffix 新增主键广播的频道后缀，见 WithInvalidationBus
+	existTopicSuffix = ":exist"
+)
+
+// ExistenceFilter 存在性过滤器，bloom.Bloom 与 bloom.Cuckoo 均满足
+//
+// Contains 返回 false 时数据一定不存在，不再查询缓存与数据库，用于防止缓存穿透
+type ExistenceFilter interface {
+	Add(bytes []byte)
+	Contains(bytes []byte) bool
+}
+
+// deletableFilter 支持删除的过滤器，如 bloom.Cuckoo
+type deletableFilter interface {
+	Del(bytes []byte)
+}
+
+// existence 并发安全的过滤器封装
+type existence struct {
+	mu     sync.RWMutex
+	filter ExistenceFilter
+
+	// rebuilding 重建中的过滤器，重建期间的写入同时写入两个过滤器
+	rebuilding ExistenceFilter
+}
+
+// WithExistenceFilter 设置存在性过滤器
+//
+// 过滤器需要包含所有已存在的主键，可以预先填充，或者调用 RebuildExistenceFilter 从数据库中重建
+// Set、Update 以及从数据库中加载成功的数据会写入过滤器，过滤器支持 Del 时，Delete 会从过滤器中删除
+//
+// 过滤器只在当前进程中，多个实例时需要设置 WithInvalidationBus，Set、Update 新增的主键会广播到其它实例，
+// 否则其它实例新增的数据在本实例中会返回 ErrNotFound
+func (e *entity) WithExistenceFilter(filter ExistenceFilter) Entity {
+	e.existence.Store(&existence{filter: filter})
+	return e
+}
+
+// RebuildExistenceFilter 扫描读数据库中所有主键写入 filter，完成后替换当前过滤器
+//
+// filter 应为新建的空过滤器，扫描期间的 Set、Update、Delete 同时作用于 filter
+// 读数据库需要实现 IDScanner
+func (e *entity) RebuildExistenceFilter(ctx context.Context, model interface{}, filter ExistenceFilter) error {
+	if len(e.readDBs) == 0 {
+		return ErrNoReadDB
+	}
+
+	scanner, ok := e.readDBs[0].(IDScanner)
+	if !ok {
+		return ErrNoIDScanner
+	}
+
+	// 未设置过滤器时创建，可能与 mayExist 等并发执行
+	e.existence.CompareAndSwap(nil, &existence{})
+	ex := e.existence.Load()
+
+	ex.mu.Lock()
+	ex.rebuilding = filter
+	ex.mu.Unlock()
+
+	var afterID uint64
+	for {
+		ids, err := scanner.ScanIDsCtx(ctx, model, afterID, scanBatchSize)
+		if err != nil {
+			ex.mu.Lock()
+			ex.rebuilding = nil
+			ex.mu.Unlock()
+			return err
+		}
+
+		ex.mu.Lock()
+		for _, id := range ids {
+			filter.Add(zerobytes.PutUint64(id))
+		}
+		ex.mu.Unlock()
+
+		if len(ids) < scanBatchSize {
+			break
+		}
+		afterID = ids[len(ids)-1]
+	}
+
+	ex.mu.Lock()
+	ex.filter = filter
+	ex.rebuilding = nil
+	ex.mu.Unlock()
+
+	return nil
+}
+
+// mayExist 数据是否可能存在，未设置过滤器时总是返回 true
+func (e *entity) mayExist(id uint64) bool {
+	ex := e.existence.Load()
+	if ex == nil {
+		return true
+	}
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	if ex.filter == nil {
+		return true
+	}
+
+	if ex.filter.Contains(zerobytes.PutUint64(id)) {
+		return true
+	}
+
+	if e.st != nil {
+		e.st.incFiltered()
+	}
+	return false
+}
+
+// markExist 将主键写入过滤器
+func (e *entity) markExist(ids ...uint64) {
+	ex := e.existence.Load()
+	if ex == nil {
+		return
+	}
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	for _, id := range ids {
+		bs := zerobytes.PutUint64(id)
+		if ex.filter != nil {
+			ex.filter.Add(bs)
+		}
+		if ex.rebuilding != nil {
+			ex.rebuilding.Add(bs)
+		}
+	}
+}
+
+// announceExist 将主键写入过滤器，并广播到其它实例
+func (e *entity) announceExist(ctx context.Context, ids ...uint64) {
+	e.markExist(ids...)
+
+	if e.bus == nil || e.existence.Load() == nil || len(ids) == 0 {
+		return
+	}
+
+	if err := e.bus.Publish(ctx, e.busTopic+existTopicSuffix, encodeInvalidation(e.node, ids...)); err != nil {
+		e.logger.Errorf("failed to publish existence, ids: %v, err: %s", ids, err.Error())
+	}
+}
+
+// onExist 收到其它实例新增的主键，写入过滤器
+func (e *entity) onExist(msg []byte) {
+	node, ids, err := decodeInvalidation(msg)
+	if err != nil {
+		e.logger.Errorf("failed to decode existence, err: %s", err.Error())
+		return
+	}
+
+	if node == e.node {
+		return
+	}
+
+	e.markExist(ids...)
+}
+
+// unmarkExist 从过滤器中删除主键，过滤器不支持删除时忽略
+func (e *entity) unmarkExist(ids ...uint64) {
+	ex := e.existence.Load()
+	if ex == nil {
+		return
+	}
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	for _, filter := range []ExistenceFilter{ex.filter, ex.rebuilding} {
+		d, ok := filter.(deletableFilter)
+		if !ok {
+			continue
+		}
+		for _, id := range ids {
+			d.Del(zerobytes.PutUint64(id))
+		}
+	}
+}
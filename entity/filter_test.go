@@ -0,0 +1,240 @@
+package entity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	zerobloom "github.com/zerogo-hub/zero-helper/bloom"
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	"github.com/zerogo-hub/zero-helper/entity/entitytest"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+// scanDB 仅用于重建过滤器
+type scanDB struct {
+	ids []uint64
+}
+
+func (db *scanDB) Get(out interface{}, id uint64) error {
+	return db.GetCtx(context.Background(), out, id)
+}
+
+func (db *scanDB) GetCtx(ctx context.Context, out interface{}, id uint64) error {
+	_, _, err := queryAccounts(out, id)
+	return err
+}
+
+func (db *scanDB) MGet(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	return queryAccounts(out, ids...)
+}
+
+func (db *scanDB) MGetCtx(ctx context.Context, out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	return queryAccounts(out, ids...)
+}
+
+func (db *scanDB) ScanIDs(model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	return db.ScanIDsCtx(context.Background(), model, afterID, limit)
+}
+
+func (db *scanDB) ScanIDsCtx(ctx context.Context, model interface{}, afterID uint64, limit int) ([]uint64, error) {
+	ids := []uint64{}
+	for _, id := range db.ids {
+		if id > afterID && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (db *scanDB) ErrNotFound() error {
+	return zeroentity.ErrNotFound
+}
+
+func TestExistenceFilter(t *testing.T) {
+	st := zeroentity.NewStat("filter", nil)
+	queries := 0
+
+	e := zeroentity.New(st, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		queries++
+		return queryAccounts(out, ids...)
+	})
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithExistenceFilter(zerobloom.NewCuckoo(1024))
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	if _, err := typed.Get(1); err != zeroentity.ErrNotFound {
+		t.Errorf("test Get failed, err: %v", err)
+	}
+	if queries != 0 || st.Snapshot().Filtered != 1 {
+		t.Errorf("test filter failed, queries: %d", queries)
+	}
+
+	if err := typed.Set(account{UUID: 100, Username: "zero100"}, 100); err != nil {
+		t.Fatalf("test Set failed: %s", err.Error())
+	}
+	if a, err := typed.Get(100); err != nil || a.Username != "zero100" {
+		t.Errorf("test Get after Set failed, account: %v, err: %v", a, err)
+	}
+
+	list, err := typed.MGet(100, 1)
+	if err != nil || len(list) != 1 || list[0].UUID != 100 {
+		t.Errorf("test MGet failed, list: %v, err: %v", list, err)
+	}
+
+	if err := typed.Delete(100); err != nil {
+		t.Fatalf("test Delete failed: %s", err.Error())
+	}
+	if _, err := typed.Get(100); err != zeroentity.ErrNotFound || queries != 0 {
+		t.Errorf("test Get after Delete failed, queries: %d, err: %v", queries, err)
+	}
+}
+
+func TestExistenceFilterFailedUpdate(t *testing.T) {
+	db := entitytest.NewDB(func(in interface{}) uint64 { return in.(*entitytest.Row).ID })
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(db)
+	e.WithWriteDB(db)
+	e.WithExistenceFilter(zerobloom.NewCuckoo(1024))
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[entitytest.Row](e)
+
+	// 写入失败时不标记存在
+	db.FailWith(errors.New("db down"), 1)
+	if err := typed.Update(&entitytest.Row{ID: 7, Name: "zero7"}, 7); err == nil {
+		t.Fatal("test Update failed, expected error")
+	}
+	if _, err := typed.Get(7); err != zeroentity.ErrNotFound || db.Calls("Get") != 0 {
+		t.Errorf("test Get after failed Update failed, calls: %d, err: %v", db.Calls("Get"), err)
+	}
+
+	if err := typed.Update(&entitytest.Row{ID: 7, Name: "zero7"}, 7); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if row, err := typed.Get(7); err != nil || row.Name != "zero7" {
+		t.Errorf("test Get after Update failed, row: %v, err: %v", row, err)
+	}
+}
+
+func TestRebuildExistenceFilter(t *testing.T) {
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(&scanDB{ids: []uint64{1, 2, 3}})
+	e.WithExistenceFilter(zerobloom.New(1024, 0.001))
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+	if _, err := typed.Get(2); err != zeroentity.ErrNotFound {
+		t.Errorf("test Get before rebuild failed, err: %v", err)
+	}
+
+	if err := e.RebuildExistenceFilter(context.Background(), &account{}, zerobloom.New(1024, 0.001)); err != nil {
+		t.Fatalf("test RebuildExistenceFilter failed: %s", err.Error())
+	}
+
+	if a, err := typed.Get(2); err != nil || a.Username != "zero2" {
+		t.Errorf("test Get after rebuild failed, account: %v, err: %v", a, err)
+	}
+}
+
+// plainDB 未实现 IDScanner 的读数据库
+type plainDB struct {
+	zeroentity.WrapReadDB
+}
+
+func TestRebuildExistenceFilterConcurrent(t *testing.T) {
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithReadDB(&scanDB{ids: []uint64{1, 2, 3}})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	// 未设置过滤器时重建，与读取并发执行
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			typed.Get(2)
+		}
+	}()
+
+	if err := e.RebuildExistenceFilter(context.Background(), &account{}, zerobloom.New(1024, 0.001)); err != nil {
+		t.Fatalf("test RebuildExistenceFilter failed: %s", err.Error())
+	}
+	<-done
+
+	if a, err := typed.Get(2); err != nil || a.Username != "zero2" {
+		t.Errorf("test Get after rebuild failed, account: %v, err: %v", a, err)
+	}
+
+	plain := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	plain.WithReadDB(plainDB{&scanDB{}})
+	plain.Build()
+	defer plain.Close()
+
+	if err := plain.RebuildExistenceFilter(context.Background(), &account{}, zerobloom.New(1024, 0.001)); err != zeroentity.ErrNoIDScanner {
+		t.Errorf("test RebuildExistenceFilter without IDScanner failed, err: %v", err)
+	}
+}
+
+func TestExistenceFilterAcrossInstances(t *testing.T) {
+	db := entitytest.NewDB(func(in interface{}) uint64 { return in.(*entitytest.Row).ID })
+	transport := zeroentity.NewMemoryTransport()
+
+	newInstance := func() zeroentity.Entity {
+		e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+		e.WithReadDB(db)
+		e.WithWriteDB(db)
+		e.WithExistenceFilter(zerobloom.NewCuckoo(1024))
+		e.WithInvalidationBus(transport, "row")
+		e.Build()
+		return e
+	}
+
+	e1, e2 := newInstance(), newInstance()
+	defer e1.Close()
+	defer e2.Close()
+
+	typed1, typed2 := zeroentity.NewTyped[entitytest.Row](e1), zeroentity.NewTyped[entitytest.Row](e2)
+
+	// 实例 1 新增的数据，实例 2 可以读取
+	if err := typed1.Update(&entitytest.Row{ID: 9, Name: "zero9"}, 9); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if row, err := typed2.Get(9); err != nil || row.Name != "zero9" {
+		t.Errorf("test Get on other instance failed, row: %v, err: %v", row, err)
+	}
+
+	// 事务中删除，提交后从过滤器中删除，回滚时保留
+	u := zeroentity.NewUnitOfWork()
+	if err := typed1.DeleteCtx(zeroentity.ContextWithUnitOfWork(context.Background(), u), 9); err != nil {
+		t.Fatalf("test DeleteCtx failed: %s", err.Error())
+	}
+	u.Rollback()
+	n := db.Calls("Get")
+	typed1.Get(9)
+	if db.Calls("Get") != n+1 {
+		t.Error("test Get after rollback failed, filtered")
+	}
+
+	u = zeroentity.NewUnitOfWork()
+	if err := typed1.DeleteCtx(zeroentity.ContextWithUnitOfWork(context.Background(), u), 9); err != nil {
+		t.Fatalf("test DeleteCtx failed: %s", err.Error())
+	}
+	u.Commit(context.Background())
+	n = db.Calls("Get")
+	if _, err := typed1.Get(9); err != zeroentity.ErrNotFound || db.Calls("Get") != n {
+		t.Errorf("test Get after commit failed, calls: %d, err: %v", db.Calls("Get")-n, err)
+	}
+}
@@ -0,0 +1,293 @@
+package entity
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// sketchDepth count-min sketch 的哈希函数数量
+	sketchDepth = 4
+	// sketchWidth count-min sketch 每行的计数器数量，2 的 n 次方
+	sketchWidth = 4096
+)
+
+// HotKeyConfig 热点主键探测配置
+type HotKeyConfig struct {
+	// SampleRate 采样率 (0, 1]，默认 1，即每次读取都计数
+	SampleRate float64
+
+	// TopK 记录最热的主键数量，默认 100
+	TopK int
+
+	// Threshold 采样计数达到该值才认为是热点，默认 10
+	Threshold uint64
+
+	// Window 衰减周期，每个周期所有计数减半，默认 1 分钟
+	Window time.Duration
+
+	// Promote 是否只在本地缓存中保存热点数据
+	// 开启后，同时设置了远端缓存时，非热点数据只写入远端缓存，远端缓存命中热点数据时写入本地缓存
+	Promote bool
+}
+
+// HotKey 热点主键
+type HotKey struct {
+	ID uint64
+	// Count 当前周期内采样计数的估计值 (含衰减)
+	Count uint64
+}
+
+// hotKeys 基于 count-min sketch 与 top-k 的热点主键探测，并发安全
+//
+// sketch 的计数器使用原子操作，只有计数达到 Threshold 的主键才需要加锁更新 top-k
+type hotKeys struct {
+	conf HotKeyConfig
+
+	sketch [sketchDepth][sketchWidth]uint32
+
+	mu sync.RWMutex
+	// top 按照计数的最小堆，堆顶为 top-k 中计数最小的主键
+	top hotHeap
+	// entries 主键在 top 中的位置
+	entries map[uint64]*hotEntry
+}
+
+// hotEntry top-k 中的主键
+type hotEntry struct {
+	id    uint64
+	count uint64
+	index int
+}
+
+// hotHeap 按照计数的最小堆，实现 heap.Interface
+type hotHeap []*hotEntry
+
+func (h hotHeap) Len() int           { return len(h) }
+func (h hotHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h hotHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *hotHeap) Push(x interface{}) {
+	entry := x.(*hotEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *hotHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func newHotKeys(conf HotKeyConfig) *hotKeys {
+	if conf.SampleRate <= 0 || conf.SampleRate > 1 {
+		conf.SampleRate = 1
+	}
+	if conf.TopK <= 0 {
+		conf.TopK = 100
+	}
+	if conf.Threshold == 0 {
+		conf.Threshold = 10
+	}
+	if conf.Window <= 0 {
+		conf.Window = time.Minute
+	}
+
+	return &hotKeys{
+		conf:    conf,
+		top:     make(hotHeap, 0, conf.TopK),
+		entries: make(map[uint64]*hotEntry, conf.TopK),
+	}
+}
+
+// WithHotKey 开启热点主键探测，热点通过 Stat 输出
+func (e *entity) WithHotKey(conf HotKeyConfig) Entity {
+	e.hot = newHotKeys(conf)
+	return e
+}
+
+// HotKeys 当前最热的主键，按照计数降序
+func (e *entity) HotKeys() []HotKey {
+	if e.hot == nil {
+		return nil
+	}
+	return e.hot.list()
+}
+
+// record 记录一次读取
+func (h *hotKeys) record(id uint64) {
+	if h.conf.SampleRate < 1 && rand.Float64() >= h.conf.SampleRate {
+		return
+	}
+
+	count := h.increment(id)
+	if count < h.conf.Threshold {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if entry, ok := h.entries[id]; ok {
+		entry.count = count
+		heap.Fix(&h.top, entry.index)
+		return
+	}
+
+	if len(h.top) < h.conf.TopK {
+		entry := &hotEntry{id: id, count: count}
+		heap.Push(&h.top, entry)
+		h.entries[id] = entry
+		return
+	}
+
+	// 替换 top-k 中计数最小的主键
+	if least := h.top[0]; least.count < count {
+		delete(h.entries, least.id)
+		least.id, least.count = id, count
+		h.entries[id] = least
+		heap.Fix(&h.top, 0)
+	}
+}
+
+// increment sketch 中每行的计数器加一，返回估计值，即各行计数的最小值
+func (h *hotKeys) increment(id uint64) uint64 {
+	count := uint64(^uint32(0))
+	for row := 0; row < sketchDepth; row++ {
+		counter := &h.sketch[row][sketchIndex(id, row)]
+		for {
+			c := atomic.LoadUint32(counter)
+			if c == ^uint32(0) {
+				break
+			}
+			if atomic.CompareAndSwapUint32(counter, c, c+1) {
+				c++
+				if uint64(c) < count {
+					count = uint64(c)
+				}
+				break
+			}
+		}
+	}
+	return count
+}
+
+// isHot 是否为热点主键
+func (h *hotKeys) isHot(id uint64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	entry, ok := h.entries[id]
+	return ok && entry.count >= h.conf.Threshold
+}
+
+// list 热点主键，按照计数降序
+func (h *hotKeys) list() []HotKey {
+	h.mu.RLock()
+	keys := make([]HotKey, 0, len(h.top))
+	for _, entry := range h.top {
+		if entry.count >= h.conf.Threshold {
+			keys = append(keys, HotKey{ID: entry.id, Count: entry.count})
+		}
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count == keys[j].Count {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].Count > keys[j].Count
+	})
+
+	return keys
+}
+
+// decay 所有计数减半，使探测结果跟随访问模式变化
+func (h *hotKeys) decay() {
+	for row := range h.sketch {
+		for col := range h.sketch[row] {
+			counter := &h.sketch[row][col]
+			for {
+				c := atomic.LoadUint32(counter)
+				if atomic.CompareAndSwapUint32(counter, c, c>>1) {
+					break
+				}
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	top := h.top[:0]
+	for _, entry := range h.top {
+		if entry.count >>= 1; entry.count == 0 {
+			delete(h.entries, entry.id)
+		} else {
+			entry.index = len(top)
+			top = append(top, entry)
+		}
+	}
+	h.top = top
+	heap.Init(&h.top)
+}
+
+// sketchIndex 第 row 个哈希函数的位置 (splitmix64)
+func sketchIndex(id uint64, row int) int {
+	x := id + uint64(row+1)*0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return int(x & (sketchWidth - 1))
+}
+
+// recordRead 记录读取，用于热点主键探测
+func (e *entity) recordRead(ids ...uint64) {
+	if e.hot == nil {
+		return
+	}
+	for _, id := range ids {
+		e.hot.record(id)
+	}
+}
+
+// keepLocal 数据是否写入本地缓存
+//
+// 开启 Promote 且设置了远端缓存时，只有热点数据写入本地缓存，二级键的映射不受影响
+func (e *entity) keepLocal(id uint64) bool {
+	if e.hot == nil || !e.hot.conf.Promote || e.remoteCache == nil {
+		return true
+	}
+	if id&indexBit != 0 {
+		return true
+	}
+	return e.hot.isHot(id)
+}
+
+// promote 远端缓存命中时，将热点数据写入本地缓存
+func (e *entity) promote(ctx context.Context, ids []uint64, datas [][]byte) {
+	if e.hot == nil || !e.hot.conf.Promote || e.localCache == nil {
+		return
+	}
+
+	for idx, id := range ids {
+		if !e.hot.isHot(id) || IsEmptyPlaceholder(datas[idx]) {
+			continue
+		}
+		if err := e.localCache.SetExCtx(ctx, id, datas[idx], e.expire(e.ttl)); err != nil {
+			e.logger.Errorf("promote to local cache failed, id: %d, err: %s", id, err.Error())
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package entity_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestHotKey(t *testing.T) {
+	st := zeroentity.NewStat("hot", nil)
+	local := zeroentitycache.NewBigCache(time.Minute)
+	remote := zeroentitycache.NewBigCache(time.Minute)
+
+	e := zeroentity.New(st, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithRemoteCache(remote)
+	e.WithCustomQueryHandler(queryAccounts)
+	e.WithHotKey(zeroentity.HotKeyConfig{Threshold: 3, Promote: true})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+	for i := 0; i < 5; i++ {
+		if _, err := typed.Get(1); err != nil {
+			t.Fatalf("test Get failed: %s", err.Error())
+		}
+	}
+	if _, err := typed.Get(2); err != nil {
+		t.Fatalf("test Get failed: %s", err.Error())
+	}
+
+	// 冷数据只存在于远端缓存
+	if _, err := local.Get(2); err == nil {
+		t.Error("test Promote failed, cold key in local cache")
+	}
+	if _, err := remote.Get(2); err != nil {
+		t.Errorf("test Promote failed, cold key not in remote cache: %s", err.Error())
+	}
+
+	// 热点数据提升到本地缓存
+	if _, err := local.Get(1); err != nil {
+		t.Errorf("test Promote failed, hot key not in local cache: %s", err.Error())
+	}
+
+	hotKeys := st.Snapshot().HotKeys
+	if len(hotKeys) != 1 || hotKeys[0].ID != 1 || hotKeys[0].Count != 5 {
+		t.Errorf("test HotKeys failed: %v", hotKeys)
+	}
+}
+
+func TestHotKeyTopK(t *testing.T) {
+	st := zeroentity.NewStat("hot", nil)
+
+	e := zeroentity.New(st, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithCustomQueryHandler(queryAccounts)
+	e.WithHotKey(zeroentity.HotKeyConfig{Threshold: 2, TopK: 2})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	// 读取次数随主键增大，并发执行
+	var wg sync.WaitGroup
+	for id := uint64(1); id <= 3; id++ {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(id uint64) {
+				defer wg.Done()
+				for n := uint64(0); n < id*10/4+1; n++ {
+					typed.Get(id)
+				}
+			}(id)
+		}
+	}
+	wg.Wait()
+
+	hotKeys := st.Snapshot().HotKeys
+	if len(hotKeys) != 2 || hotKeys[0].ID != 3 || hotKeys[1].ID != 2 {
+		t.Errorf("test HotKeys with TopK failed: %v", hotKeys)
+	}
+}
@@ -0,0 +1,193 @@
+package entity
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	zeroutils "github.com/zerogo-hub/zero-helper/utils"
+)
+
+// ErrIndexNotFound 索引未注册
+var ErrIndexNotFound = errors.New("index not found")
+
+// indexBit 索引映射在缓存中的键的最高位，与主键区分，主键不应使用最高位
+const indexBit = uint64(1) << 63
+
+// Index 二级索引，如 用户名 -> 主键、订单号 -> 主键
+//
+// 映射关系 二级键 -> 主键 与实体数据存放于相同的本地缓存与远端缓存中，
+// 之后通过主键走正常的 Get 流程
+type Index struct {
+	// Name 索引名称，如 "username"
+	Name string
+
+	// Load 根据二级键从数据库中查找主键，数据不存在时返回 ErrNotFound 或者数据库的 ErrNotFound
+	Load func(ctx context.Context, key string) (uint64, error)
+
+	// Key 从实体中取出二级键，可选
+	// 设置后，读取时会校验映射是否过期，Update、Delete 时会删除对应的映射
+	Key func(model interface{}) string
+}
+
+// GetByKey 根据二级键获取数据
+func (e *entity) GetByKey(out interface{}, index, key string) error {
+	return e.GetByKeyCtx(context.Background(), out, index, key)
+}
+
+// GetByKeyCtx 根据二级键获取数据
+func (e *entity) GetByKeyCtx(ctx context.Context, out interface{}, index, key string) error {
+	idx, ok := e.indexes[index]
+	if !ok {
+		return ErrIndexNotFound
+	}
+
+	id, err := e.resolveKey(ctx, idx, key, true)
+	if err != nil {
+		return err
+	}
+
+	err = e.get(ctx, out, id, nil)
+	if err == nil && (idx.Key == nil || idx.Key(out) == key) {
+		return nil
+	}
+	if err != nil && !e.isNotFound(id, err) {
+		return err
+	}
+
+	// 映射已过期，如二级键被修改或者数据被删除，重新从数据库中查找
+	e.RemoveKey(index, key)
+
+	id, err = e.resolveKey(ctx, idx, key, false)
+	if err != nil {
+		return err
+	}
+
+	return e.get(ctx, out, id, nil)
+}
+
+// RemoveKey 删除二级键的映射缓存
+func (e *entity) RemoveKey(index, key string) {
+	e.removeKeys(context.Background(), index, key)
+}
+
+// WithIndex 注册二级索引
+func (e *entity) WithIndex(index *Index) Entity {
+	if e.indexes == nil {
+		e.indexes = make(map[string]*Index)
+	}
+	e.indexes[index.Name] = index
+	return e
+}
+
+// resolveKey 查找二级键对应的主键，useCache 为 false 时直接从数据库中查找
+func (e *entity) resolveKey(ctx context.Context, idx *Index, key string, useCache bool) (uint64, error) {
+	mid := genIndexID(idx.Name, key)
+
+	ch := e.g.DoChan(genSingleFlightKey(mid), func() (interface{}, error) {
+		if useCache {
+			if e.localCache != nil {
+				id, err := e.getKeyFromCache(ctx, e.localCache, key, mid)
+				if err == nil || err == ErrNotFound {
+					return id, err
+				}
+			}
+
+			if e.remoteCache != nil {
+				id, err := e.getKeyFromCache(ctx, e.remoteCache, key, mid)
+				if err == nil || err == ErrNotFound {
+					return id, err
+				}
+			}
+		}
+
+		id, err := idx.Load(ctx, key)
+		if err != nil {
+			if ctx.Err() == nil && e.isNotFound(id, err) {
+				e.setCacheWithNotFound(ctx, mid)
+			}
+			return uint64(0), err
+		}
+
+		e.setCache(ctx, mid, encodeIndexRecord(id, key))
+
+		return id, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(e.timeout):
+		return 0, ErrTimeout
+	case ret := <-ch:
+		if ret.Err != nil {
+			return 0, ret.Err
+		}
+		return ret.Val.(uint64), nil
+	}
+}
+
+// getKeyFromCache 从缓存中查找映射，哈希冲突时视为未命中
+func (e *entity) getKeyFromCache(ctx context.Context, cache WrapCache, key string, mid uint64) (uint64, error) {
+	data, err := cache.GetCtx(ctx, mid)
+	if err != nil {
+		return 0, err
+	}
+
+	if IsEmptyPlaceholder(data) {
+		return 0, ErrNotFound
+	}
+
+	id, k, ok := decodeIndexRecord(data)
+	if !ok || k != key {
+		return 0, cache.ErrNotFound()
+	}
+
+	return id, nil
+}
+
+// invalidateKeys 更新、删除数据时，删除 model 中二级键的映射
+//
+// 新的二级键之前可能被设置为不存在的短期缓存，必须删除；
+// 旧的二级键在读取时通过 Index.Key 校验发现过期
+func (e *entity) invalidateKeys(ctx context.Context, model interface{}) {
+	for name, idx := range e.indexes {
+		if idx.Key == nil {
+			continue
+		}
+
+		if key := idx.Key(model); key != "" {
+			e.removeKeys(ctx, name, key)
+		}
+	}
+}
+
+func (e *entity) removeKeys(ctx context.Context, index string, keys ...string) {
+	mids := make([]uint64, 0, len(keys))
+	for _, key := range keys {
+		mids = append(mids, genIndexID(index, key))
+	}
+
+	e.invalidateCache(ctx, mids...)
+}
+
+// genIndexID 计算二级键在缓存中的键
+func genIndexID(index, key string) uint64 {
+	return zeroutils.ToUint64(index+":"+key) | indexBit
+}
+
+// encodeIndexRecord 映射格式: 主键 (8 字节) + 二级键，保存二级键用于校验哈希冲突
+func encodeIndexRecord(id uint64, key string) []byte {
+	out := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(out, id)
+	copy(out[8:], key)
+	return out
+}
+
+func decodeIndexRecord(in []byte) (uint64, string, bool) {
+	if len(in) < 8 {
+		return 0, "", false
+	}
+	return binary.BigEndian.Uint64(in), string(in[8:]), true
+}
@@ -0,0 +1,90 @@
+package entity_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestGetByKey(t *testing.T) {
+	var mu sync.Mutex
+	rows := map[uint64]account{
+		1: {UUID: 1, Username: "zero1"},
+		2: {UUID: 2, Username: "zero2"},
+	}
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		a, ok := rows[ids[0]]
+		if !ok {
+			return nil, nil, zeroentity.ErrNotFound
+		}
+		*out.(*account) = a
+		return ids, []interface{}{a}, nil
+	})
+	e.WithCustomUpdateHandler(func(out interface{}, ids ...uint64) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		rows[ids[0]] = *out.(*account)
+		return nil
+	})
+	e.WithIndex(&zeroentity.Index{
+		Name: "username",
+		Load: func(ctx context.Context, key string) (uint64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			for id, a := range rows {
+				if a.Username == key {
+					return id, nil
+				}
+			}
+			return 0, zeroentity.ErrNotFound
+		},
+		Key: func(model interface{}) string {
+			return model.(*account).Username
+		},
+	})
+	e.Build()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	a, err := typed.GetByKey("username", "zero2")
+	if err != nil || a.UUID != 2 {
+		t.Fatalf("test GetByKey failed, account: %v, err: %v", a, err)
+	}
+
+	// 不存在的二级键，设置短期缓存
+	if _, err := typed.GetByKey("username", "two"); err != zeroentity.ErrNotFound {
+		t.Fatalf("test GetByKey not found failed, err: %v", err)
+	}
+
+	// 修改二级键
+	if err := typed.Update(&account{UUID: 2, Username: "two"}, 2); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+
+	a, err = typed.GetByKey("username", "two")
+	if err != nil || a.UUID != 2 {
+		t.Errorf("test GetByKey after update failed, account: %v, err: %v", a, err)
+	}
+
+	if _, err := typed.GetByKey("username", "zero2"); err != zeroentity.ErrNotFound {
+		t.Errorf("test GetByKey with stale key failed, err: %v", err)
+	}
+
+	if _, err := typed.GetByKey("email", "zero2"); err != zeroentity.ErrIndexNotFound {
+		t.Errorf("test GetByKey with unknown index failed, err: %v", err)
+	}
+}
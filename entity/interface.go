@@ -1,11 +1,14 @@
 package entity
 
 import (
+	"bytes"
+	"context"
 	"errors"
 
 	"time"
 
 	zerocodec "github.com/zerogo-hub/zero-helper/codec"
+	zerocompress "github.com/zerogo-hub/zero-helper/compress"
 )
 
 var (
@@ -21,12 +24,19 @@ var (
 	ErrResultIdNotFound = errors.New("id not found")
 	// ErrIDCantBeNull ID 不可以为空
 	ErrIDCantBeNull = errors.New("id cant be null")
+	// ErrSomeNotFound 批量查找时部分数据未找到
+	ErrSomeNotFound = errors.New("some data not found")
 )
 
 var (
 	emptyPlaceholder = []byte("__z_")
 )
 
+// IsEmptyPlaceholder 是否为数据不存在时设置的短期缓存
+func IsEmptyPlaceholder(in []byte) bool {
+	return bytes.Equal(in, emptyPlaceholder)
+}
+
 // QueryHandler 查询函数
 type QueryHandler func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error)
 
@@ -47,6 +57,9 @@ type Entity interface {
 	// Get 根据主键获取数据
 	Get(out interface{}, id uint64) error
 
+	// GetCtx 根据主键获取数据，ctx 的截止时间与取消会传递到数据库与缓存
+	GetCtx(ctx context.Context, out interface{}, id uint64) error
+
 	// GetWithQuery 根据主键获取数据
 	// query 自定义查询
 	GetWithQuery(out interface{}, id uint64, query QueryHandler) error
@@ -54,24 +67,69 @@ type Entity interface {
 	// MGet 根据主键批量获取数据
 	MGet(out interface{}, ids ...uint64) (*Result, error)
 
+	// MGetCtx 根据主键批量获取数据
+	MGetCtx(ctx context.Context, out interface{}, ids ...uint64) (*Result, error)
+
+	// GetByKey 根据二级键获取数据，index 为 WithIndex 注册的索引名称
+	GetByKey(out interface{}, index, key string) error
+
+	// GetByKeyCtx 根据二级键获取数据
+	GetByKeyCtx(ctx context.Context, out interface{}, index, key string) error
+
 	// Set 缓存数据
 	Set(in interface{}, id uint64) error
 
 	// Update 更新数据库，更新缓存
 	Update(model interface{}, id uint64) error
 
+	// UpdateCtx 更新数据库，更新缓存
+	UpdateCtx(ctx context.Context, model interface{}, id uint64) error
+
 	// Delete 删除数据库，删除缓存
 	Delete(model interface{}, id uint64) error
 
+	// DeleteCtx 删除数据库，删除缓存
+	DeleteCtx(ctx context.Context, model interface{}, id uint64) error
+
 	// MDelete 批量删除数据库，删除缓存
 	MDelete(model interface{}, ids ...uint64) error
 
+	// MDeleteCtx 批量删除数据库，删除缓存
+	MDeleteCtx(ctx context.Context, model interface{}, ids ...uint64) error
+
 	// RemoveCache 仅删除缓存
 	RemoveCache(id uint64)
 
+	// Invalidate 按照失效策略删除缓存，并删除 model 对应的二级键映射，model 可以为 nil
+	Invalidate(ctx context.Context, model interface{}, ids ...uint64)
+
+	// RemoveKey 仅删除二级键的映射缓存
+	RemoveKey(index, key string)
+
+	// Flush 立即将 write-behind 中等待的数据写入数据库
+	Flush(ctx context.Context) error
+
+	// Close 写入等待的数据，停止定时器
+	Close() error
+
+	// Preload 预热缓存，分批加载 source 中的主键并写入缓存
+	Preload(ctx context.Context, out interface{}, source IDSource, conf PreloadConfig) (PreloadProgress, error)
+
+	// HotKeys 当前最热的主键，需要先调用 WithHotKey
+	HotKeys() []HotKey
+
+	// BreakerStates 每个读数据库的熔断状态，需要先调用 WithCircuitBreaker
+	BreakerStates() []BreakerState
+
+	// RebuildExistenceFilter 扫描读数据库中所有主键，重建存在性过滤器
+	RebuildExistenceFilter(ctx context.Context, model interface{}, filter ExistenceFilter) error
+
 	WithCodec(codec zerocodec.Codec) Entity
 	WithTimeout(timeout time.Duration) Entity
 	WithNotFoundExipred(expired time.Duration) Entity
+	WithCacheTTL(ttl time.Duration) Entity
+	WithJitter(jitter time.Duration) Entity
+	WithSoftTTL(soft time.Duration) Entity
 	WithReadDB(dbs ...WrapReadDB) Entity
 	WithWriteDB(db WrapWriteDB) Entity
 	WithLocalCache(localCache WrapCache) Entity
@@ -79,31 +137,65 @@ type Entity interface {
 	WithCustomQueryHandler(handler QueryHandler) Entity
 	WithCustomUpdateHandler(handler UpdateHandler) Entity
 	WithCustomDeleteHandler(handler DeleteHandler) Entity
+	WithInvalidationBus(transport InvalidationTransport, topic string) Entity
+	WithIndex(index *Index) Entity
+	WithWriteThrough() Entity
+	WithWriteBehind(conf WriteBehindConfig) Entity
+	WithExistenceFilter(filter ExistenceFilter) Entity
+	WithHotKey(conf HotKeyConfig) Entity
+	WithCircuitBreaker(conf BreakerConfig) Entity
+	WithSchemaVersion(version uint16) Entity
+	WithUpgrade(version uint16, handler UpgradeHandler) Entity
+	WithCompress(compress zerocompress.Compress, minSize int) Entity
+	WithInvalidation(strategy InvalidationStrategy) Entity
+	WithInvalidateHook(hook InvalidateHook) Entity
 }
 
 // WrapReadDB 封装读数据库
 type WrapReadDB interface {
 	Get(out interface{}, id uint64) error
+	GetCtx(ctx context.Context, out interface{}, id uint64) error
 	MGet(out interface{}, ids ...uint64) ([]uint64, []interface{}, error)
+	MGetCtx(ctx context.Context, out interface{}, ids ...uint64) ([]uint64, []interface{}, error)
 	ErrNotFound() error
 }
 
+// IDScanner 可选，由 WrapReadDB 实现，用于 RebuildExistenceFilter 与 ScanIDs
+type IDScanner interface {
+	// ScanIDs 按照主键升序返回大于 afterID 的至多 limit 个主键
+	ScanIDs(model interface{}, afterID uint64, limit int) ([]uint64, error)
+	ScanIDsCtx(ctx context.Context, model interface{}, afterID uint64, limit int) ([]uint64, error)
+}
+
 // WrapWriteDB 封装写数据库
 type WrapWriteDB interface {
 	Update(in interface{}) error
+	UpdateCtx(ctx context.Context, in interface{}) error
 	Delete(model interface{}, id uint64) error
+	DeleteCtx(ctx context.Context, model interface{}, id uint64) error
 	MDelete(model interface{}, ids ...uint64) error
+	MDeleteCtx(ctx context.Context, model interface{}, ids ...uint64) error
 	ErrNotFound() error
 }
 
 // WrapCache 封装缓存
 type WrapCache interface {
 	Get(id uint64) ([]byte, error)
+	GetCtx(ctx context.Context, id uint64) ([]byte, error)
 	MGet(ids ...uint64) ([]*Value, error)
+	MGetCtx(ctx context.Context, ids ...uint64) ([]*Value, error)
 	Set(id uint64, in []byte) error
+	SetCtx(ctx context.Context, id uint64, in []byte) error
+	// SetExCtx 设置缓存并指定过期时间，ttl <= 0 时使用缓存自身的默认过期时间
+	SetExCtx(ctx context.Context, id uint64, in []byte, ttl time.Duration) error
 	MSet(ids []uint64, datas [][]byte) error
+	MSetCtx(ctx context.Context, ids []uint64, datas [][]byte) error
+	// MSetExCtx 批量设置缓存，ttls 与 ids 一一对应
+	MSetExCtx(ctx context.Context, ids []uint64, datas [][]byte, ttls []time.Duration) error
 	Delete(id uint64) error
+	DeleteCtx(ctx context.Context, id uint64) error
 	MDelete(ids ...uint64) error
+	MDeleteCtx(ctx context.Context, ids ...uint64) error
 	ErrNotFound() error
 }
 
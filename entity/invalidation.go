@@ -0,0 +1,265 @@
+package entity
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// defaultDoubleDeleteDelay 缓存双删默认的延迟时间
+const defaultDoubleDeleteDelay = 2 * time.Second
+
+// tombstonePrefix 墓碑的前缀，之后为 8 字节的写入时间，unix 毫秒
+var tombstonePrefix = []byte("__z_t")
+
+// InvalidationStrategy 缓存失效策略，数据更新或者删除后调用
+type InvalidationStrategy interface {
+	// Invalidate 使 ids 对应的缓存失效
+	Invalidate(ctx context.Context, target InvalidationTarget, ids ...uint64)
+
+	// Close 实体关闭时调用，立即执行等待中的延迟操作
+	Close()
+}
+
+// InvalidationTarget 失效策略操作的缓存
+type InvalidationTarget interface {
+	// Delete 从缓存中删除
+	Delete(ctx context.Context, ids ...uint64)
+
+	// SetTombstone 写入墓碑，有效期内读取视为未命中，
+	// 且在墓碑写入之前开始的加载不会写回缓存
+	SetTombstone(ctx context.Context, ttl time.Duration, ids ...uint64)
+}
+
+// SingleDelete 更新数据库后删除一次缓存
+func SingleDelete() InvalidationStrategy {
+	return singleDelete{}
+}
+
+type singleDelete struct{}
+
+func (singleDelete) Invalidate(ctx context.Context, target InvalidationTarget, ids ...uint64) {
+	target.Delete(ctx, ids...)
+}
+
+func (singleDelete) Close() {}
+
+// DoubleDelete 缓存双删，立即删除一次，delay 之后再删除一次，默认策略，delay 默认 2 秒
+//
+// 关闭实体时，等待中的第二次删除会立即执行
+func DoubleDelete(delay time.Duration) InvalidationStrategy {
+	if delay <= 0 {
+		delay = defaultDoubleDeleteDelay
+	}
+	return &doubleDelete{delay: delay, pending: make(map[*delayedDelete]struct{})}
+}
+
+type doubleDelete struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	pending map[*delayedDelete]struct{}
+	closed  bool
+}
+
+// delayedDelete 等待中的第二次删除
+type delayedDelete struct {
+	target InvalidationTarget
+	ids    []uint64
+	timer  *time.Timer
+}
+
+func (d *doubleDelete) Invalidate(ctx context.Context, target InvalidationTarget, ids ...uint64) {
+	target.Delete(ctx, ids...)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	dd := &delayedDelete{target: target, ids: ids}
+	d.pending[dd] = struct{}{}
+	dd.timer = time.AfterFunc(d.delay, func() {
+		if d.remove(dd) {
+			dd.target.Delete(context.Background(), dd.ids...)
+		}
+	})
+}
+
+// remove 移除等待中的删除，返回 false 表示已经被其它地方执行
+func (d *doubleDelete) remove(dd *delayedDelete) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.pending[dd]; !ok {
+		return false
+	}
+	delete(d.pending, dd)
+	return true
+}
+
+func (d *doubleDelete) Close() {
+	d.mu.Lock()
+	d.closed = true
+	pending := d.pending
+	d.pending = make(map[*delayedDelete]struct{})
+	d.mu.Unlock()
+
+	for dd := range pending {
+		dd.timer.Stop()
+		dd.target.Delete(context.Background(), dd.ids...)
+	}
+}
+
+// VersionedTombstone 使用墓碑代替删除，ttl 为墓碑的有效期，默认 2 秒
+//
+// 墓碑有效期内读取视为未命中，从数据库中加载，
+// 在墓碑写入之前开始的加载 (可能读到旧数据) 不会写回缓存，用于代替延迟删除
+func VersionedTombstone(ttl time.Duration) InvalidationStrategy {
+	if ttl <= 0 {
+		ttl = defaultDoubleDeleteDelay
+	}
+	return tombstone{ttl: ttl}
+}
+
+type tombstone struct {
+	ttl time.Duration
+}
+
+func (t tombstone) Invalidate(ctx context.Context, target InvalidationTarget, ids ...uint64) {
+	target.SetTombstone(ctx, t.ttl, ids...)
+}
+
+func (tombstone) Close() {}
+
+// BinlogDriven 更新数据库后不删除缓存，由订阅数据库 binlog 的服务调用 RemoveCache 删除
+func BinlogDriven() InvalidationStrategy {
+	return binlogDriven{}
+}
+
+type binlogDriven struct{}
+
+func (binlogDriven) Invalidate(ctx context.Context, target InvalidationTarget, ids ...uint64) {}
+
+func (binlogDriven) Close() {}
+
+// WithInvalidation 设置缓存失效策略，默认为 DoubleDelete(2 * time.Second)
+func (e *entity) WithInvalidation(strategy InvalidationStrategy) Entity {
+	e.invalidation = strategy
+	return e
+}
+
+// cacheTarget 实体的缓存，localOnly 为 true 时只操作本地缓存
+type cacheTarget struct {
+	e         *entity
+	localOnly bool
+}
+
+func (t cacheTarget) Delete(ctx context.Context, ids ...uint64) {
+	e := t.e
+
+	if e.localCache != nil {
+		if err := e.localCache.MDeleteCtx(ctx, ids...); err != nil && err != e.localCache.ErrNotFound() {
+			e.logger.Errorf("failed to delete in local cache, ids: %v, err: %s", ids, err.Error())
+		}
+	}
+
+	if e.remoteCache != nil && !t.localOnly {
+		if err := e.remoteCache.MDeleteCtx(ctx, ids...); err != nil && err != e.remoteCache.ErrNotFound() {
+			e.logger.Errorf("failed to delete in remote cache, ids: %v, err: %s", ids, err.Error())
+		}
+	}
+}
+
+func (t cacheTarget) SetTombstone(ctx context.Context, ttl time.Duration, ids ...uint64) {
+	e := t.e
+
+	datas := make([][]byte, len(ids))
+	ttls := make([]time.Duration, len(ids))
+	stone := encodeTombstone(time.Now())
+	for idx := range ids {
+		datas[idx] = stone
+		ttls[idx] = ttl
+	}
+
+	if e.localCache != nil {
+		if err := e.localCache.MSetExCtx(ctx, ids, datas, ttls); err != nil {
+			e.logger.Errorf("failed to set tombstone in local cache, ids: %v, err: %s", ids, err.Error())
+		}
+	}
+
+	if e.remoteCache != nil && !t.localOnly {
+		if err := e.remoteCache.MSetExCtx(ctx, ids, datas, ttls); err != nil {
+			e.logger.Errorf("failed to set tombstone in remote cache, ids: %v, err: %s", ids, err.Error())
+		}
+	}
+}
+
+// invalidateCache 按照失效策略使缓存失效，并通知其它实例删除本地缓存
+func (e *entity) invalidateCache(ctx context.Context, ids ...uint64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	e.invalidation.Invalidate(ctx, cacheTarget{e: e}, ids...)
+	e.publishInvalidation(ctx, ids...)
+}
+
+// freshLoaded 过滤掉加载期间被修改的数据，见 loadedBefore
+func (e *entity) freshLoaded(ctx context.Context, ids []uint64, datas [][]byte, since time.Time) ([]uint64, [][]byte) {
+	if _, ok := e.invalidation.(tombstone); !ok {
+		return ids, datas
+	}
+
+	freshIDs := make([]uint64, 0, len(ids))
+	freshDatas := make([][]byte, 0, len(datas))
+	for idx, id := range ids {
+		if !e.loadedBefore(ctx, id, since) {
+			freshIDs = append(freshIDs, id)
+			freshDatas = append(freshDatas, datas[idx])
+		}
+	}
+
+	return freshIDs, freshDatas
+}
+
+// loadedBefore 在 since 之后是否写入了墓碑，是则加载的数据可能是旧数据，不能写回缓存
+func (e *entity) loadedBefore(ctx context.Context, id uint64, since time.Time) bool {
+	if _, ok := e.invalidation.(tombstone); !ok {
+		return false
+	}
+
+	for _, cache := range []WrapCache{e.localCache, e.remoteCache} {
+		if cache == nil {
+			continue
+		}
+		data, err := cache.GetCtx(ctx, id)
+		if err != nil {
+			continue
+		}
+		if writeAt, ok := decodeTombstone(data); ok && writeAt.UnixMilli() >= since.UnixMilli() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeTombstone(at time.Time) []byte {
+	out := make([]byte, len(tombstonePrefix)+8)
+	copy(out, tombstonePrefix)
+	binary.BigEndian.PutUint64(out[len(tombstonePrefix):], uint64(at.UnixMilli()))
+	return out
+}
+
+// decodeTombstone 是否为墓碑，返回墓碑的写入时间
+func decodeTombstone(in []byte) (time.Time, bool) {
+	if len(in) != len(tombstonePrefix)+8 || !bytes.HasPrefix(in, tombstonePrefix) {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(in[len(tombstonePrefix):]))), true
+}
@@ -0,0 +1,126 @@
+package entity_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+// countTarget 记录删除次数
+type countTarget struct {
+	mu      sync.Mutex
+	deletes int
+}
+
+func (t *countTarget) Delete(ctx context.Context, ids ...uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deletes++
+}
+
+func (t *countTarget) SetTombstone(ctx context.Context, ttl time.Duration, ids ...uint64) {}
+
+func (t *countTarget) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.deletes
+}
+
+func TestDoubleDelete(t *testing.T) {
+	strategy := zeroentity.DoubleDelete(20 * time.Millisecond)
+	target := &countTarget{}
+
+	strategy.Invalidate(context.Background(), target, 1)
+	if target.count() != 1 {
+		t.Fatalf("test DoubleDelete failed, deletes: %d", target.count())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if target.count() != 2 {
+		t.Fatalf("test DoubleDelete failed, deletes: %d", target.count())
+	}
+
+	// 关闭时立即执行第二次删除
+	strategy = zeroentity.DoubleDelete(time.Hour)
+	target = &countTarget{}
+	strategy.Invalidate(context.Background(), target, 1)
+	strategy.Close()
+	if target.count() != 2 {
+		t.Errorf("test DoubleDelete close failed, deletes: %d", target.count())
+	}
+}
+
+func TestVersionedTombstone(t *testing.T) {
+	local := zeroentitycache.NewBigCache(time.Minute)
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	var queries int32
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithInvalidation(zeroentity.VersionedTombstone(time.Minute))
+	e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		if atomic.AddInt32(&queries, 1) == 1 {
+			close(loading)
+			<-release
+		}
+		// 加载期间数据已被修改，这里返回的是旧数据
+		*out.(*account) = account{UUID: 1, Username: "old"}
+		return nil, nil, nil
+	})
+	e.WithCustomUpdateHandler(func(out interface{}, id ...uint64) error { return nil })
+	e.Build()
+	defer e.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		out := &account{}
+		e.Get(out, 1)
+	}()
+
+	<-loading
+	if err := e.Update(&account{UUID: 1, Username: "new"}, 1); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	close(release)
+	<-done
+
+	// 旧数据没有写回缓存，墓碑依旧存在，读取视为未命中，重新加载
+	e.Get(&account{}, 1)
+	if n := atomic.LoadInt32(&queries); n != 2 {
+		t.Errorf("test VersionedTombstone failed, stale data cached, queries: %d", n)
+	}
+}
+
+func TestBinlogDriven(t *testing.T) {
+	local := zeroentitycache.NewBigCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithInvalidation(zeroentity.BinlogDriven())
+	e.WithCustomUpdateHandler(func(out interface{}, id ...uint64) error { return nil })
+	e.Build()
+	defer e.Close()
+
+	e.Set(&account{UUID: 1, Username: "zero1"}, 1)
+	if err := e.Update(&account{UUID: 1, Username: "one"}, 1); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if _, err := local.Get(1); err != nil {
+		t.Errorf("test BinlogDriven failed, cache removed by update: %s", err.Error())
+	}
+
+	// 由 binlog 消费者删除
+	e.RemoveCache(1)
+	if _, err := local.Get(1); err == nil {
+		t.Error("test BinlogDriven failed, cache not removed")
+	}
+}
@@ -0,0 +1,268 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrListNotFound 列表不在缓存中
+var ErrListNotFound = errors.New("list not found")
+
+// ListMember 列表成员，按照 Score 排序，Score 相同时按照 ID 排序
+type ListMember struct {
+	ID    uint64
+	Score float64
+}
+
+// ListStore 保存有序的主键列表，如 redis 有序集合、内存
+//
+// key 为列表的键，列表不存在时，读取返回 ErrListNotFound，空列表也算存在
+type ListStore interface {
+	// Replace 写入完整的列表，覆盖旧数据，ttl 为 0 表示永不过期
+	// 同时记录 成员 -> 列表 的反向索引，用于成员更新、删除时找到所在的列表
+	Replace(ctx context.Context, key string, members []ListMember, ttl time.Duration) error
+
+	// Add 列表存在时加入成员，已存在的成员更新 Score，列表不存在时忽略
+	Add(ctx context.Context, key string, members ...ListMember) error
+
+	// Remove 从列表中移除成员
+	Remove(ctx context.Context, key string, ids ...uint64) error
+
+	// Range 返回下标 [start, stop] 之间的成员，下标从 0 开始，-1 表示最后一个
+	// desc 为 true 时按照 Score 从大到小排序
+	Range(ctx context.Context, key string, start, stop int, desc bool) ([]uint64, error)
+
+	// RangeByScore 返回 Score 在 [min, max] 之间的成员，跳过 offset 个，最多返回 count 个，count <= 0 表示全部
+	RangeByScore(ctx context.Context, key string, min, max float64, offset, count int, desc bool) ([]uint64, error)
+
+	// Count 列表中成员数量
+	Count(ctx context.Context, key string) (int, error)
+
+	// Delete 删除列表
+	Delete(ctx context.Context, keys ...string) error
+
+	// Owners 成员所在的列表，来自 Replace、Add 记录的反向索引，可能包含已经不存在的列表
+	Owners(ctx context.Context, id uint64) ([]string, error)
+}
+
+// InvalidateHook 数据更新、删除后调用，model 可能为 nil
+type InvalidateHook func(ctx context.Context, model interface{}, ids ...uint64)
+
+// WithInvalidateHook 添加数据更新、删除后的回调，用于删除依赖该数据的缓存，如列表
+//
+// 在事务中时，事务提交后才会调用
+func (e *entity) WithInvalidateHook(hook InvalidateHook) Entity {
+	e.hooksMu.Lock()
+	e.hooks = append(e.hooks, hook)
+	e.hooksMu.Unlock()
+	return e
+}
+
+// runInvalidateHooks 调用 WithInvalidateHook 添加的回调
+func (e *entity) runInvalidateHooks(ctx context.Context, model interface{}, ids ...uint64) {
+	e.hooksMu.RLock()
+	hooks := e.hooks
+	e.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(ctx, model, ids...)
+	}
+}
+
+// ListConfig 列表配置
+type ListConfig struct {
+	// Name 列表名称，如 "user_orders"，列表的键为 Name + ":" + owner
+	Name string
+
+	// Store 保存列表
+	Store ListStore
+
+	// Load 列表不在缓存中时，从数据库中加载 owner 的完整列表
+	Load func(ctx context.Context, owner string) ([]ListMember, error)
+
+	// TTL 列表的过期时间，0 表示永不过期
+	TTL time.Duration
+
+	// Desc 是否按照 Score 从大到小排序，默认从小到大
+	Desc bool
+
+	// Owners 从实体中取出所属列表的 owner，可选
+	// 设置后，数据更新时同时删除新的 owner 的列表，如订单转移给了其它用户
+	Owners func(model interface{}) []string
+}
+
+// List 缓存的分页列表，如 用户的订单列表
+//
+// 列表中只保存有序的主键，分页时通过 Typed.MGetCtx 获取数据，
+// 成员更新、删除时，通过反向索引找到所在的列表并删除，之后读取时重新加载
+type List[T any] struct {
+	t    *Typed[T]
+	conf ListConfig
+	g    singleflight.Group
+}
+
+// NewList 创建一个列表，并在 t 的实体上注册成员失效的回调，可以在实体使用中创建
+func NewList[T any](t *Typed[T], conf ListConfig) *List[T] {
+	l := &List[T]{t: t, conf: conf}
+	t.Entity().WithInvalidateHook(l.onInvalidate)
+	return l
+}
+
+// Page 分页获取数据，page 从 1 开始
+// 已经不存在的数据会被忽略，所以返回的数量可能小于 size
+func (l *List[T]) Page(ctx context.Context, owner string, page, size int) ([]T, error) {
+	if page < 1 || size < 1 {
+		return nil, nil
+	}
+
+	start := (page - 1) * size
+	return l.Range(ctx, owner, start, start+size-1)
+}
+
+// Range 获取下标 [start, stop] 之间的数据
+func (l *List[T]) Range(ctx context.Context, owner string, start, stop int) ([]T, error) {
+	ids, err := l.IDs(ctx, owner, start, stop)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.resolve(ctx, owner, ids)
+}
+
+// RangeByScore 获取 Score 在 [min, max] 之间的数据，跳过 offset 个，最多返回 count 个
+func (l *List[T]) RangeByScore(ctx context.Context, owner string, min, max float64, offset, count int) ([]T, error) {
+	var ids []uint64
+	err := l.withLoad(ctx, owner, func(key string) (err error) {
+		ids, err = l.conf.Store.RangeByScore(ctx, key, min, max, offset, count, l.conf.Desc)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return l.resolve(ctx, owner, ids)
+}
+
+// IDs 获取下标 [start, stop] 之间的主键
+func (l *List[T]) IDs(ctx context.Context, owner string, start, stop int) ([]uint64, error) {
+	var ids []uint64
+	err := l.withLoad(ctx, owner, func(key string) (err error) {
+		ids, err = l.conf.Store.Range(ctx, key, start, stop, l.conf.Desc)
+		return err
+	})
+	return ids, err
+}
+
+// Count 列表中成员数量
+func (l *List[T]) Count(ctx context.Context, owner string) (int, error) {
+	var n int
+	err := l.withLoad(ctx, owner, func(key string) (err error) {
+		n, err = l.conf.Store.Count(ctx, key)
+		return err
+	})
+	return n, err
+}
+
+// Add 新增数据后调用，列表在缓存中时加入成员
+func (l *List[T]) Add(ctx context.Context, owner string, members ...ListMember) error {
+	return l.conf.Store.Add(ctx, l.key(owner), members...)
+}
+
+// Remove 从列表中移除成员
+func (l *List[T]) Remove(ctx context.Context, owner string, ids ...uint64) error {
+	return l.conf.Store.Remove(ctx, l.key(owner), ids...)
+}
+
+// Invalidate 删除列表缓存，之后读取时重新加载
+func (l *List[T]) Invalidate(ctx context.Context, owners ...string) error {
+	if len(owners) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		keys = append(keys, l.key(owner))
+	}
+	return l.conf.Store.Delete(ctx, keys...)
+}
+
+// resolve 通过主键获取数据，已不存在的数据被忽略
+//
+// 部分数据已不存在时，说明列表已过期，删除列表缓存，下次读取时重新加载
+func (l *List[T]) resolve(ctx context.Context, owner string, ids []uint64) ([]T, error) {
+	// 空列表或者超出范围的分页
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	results, err := l.t.MGetCtx(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) != len(ids) {
+		l.Invalidate(ctx, owner)
+	}
+	return results, nil
+}
+
+// withLoad 执行 fn，列表不在缓存中时加载后再执行一次
+func (l *List[T]) withLoad(ctx context.Context, owner string, fn func(key string) error) error {
+	key := l.key(owner)
+
+	err := fn(key)
+	if err != ErrListNotFound {
+		return err
+	}
+
+	_, err, _ = l.g.Do(key, func() (interface{}, error) {
+		members, err := l.conf.Load(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		return nil, l.conf.Store.Replace(ctx, key, members, l.conf.TTL)
+	})
+	if err != nil {
+		return err
+	}
+
+	return fn(key)
+}
+
+// onInvalidate 成员更新、删除时，删除所在的列表
+func (l *List[T]) onInvalidate(ctx context.Context, model interface{}, ids ...uint64) {
+	prefix := l.conf.Name + ":"
+
+	var keys []string
+	for _, id := range ids {
+		owners, err := l.conf.Store.Owners(ctx, id)
+		if err != nil {
+			continue
+		}
+		for _, key := range owners {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	if model != nil && l.conf.Owners != nil {
+		for _, owner := range l.conf.Owners(model) {
+			if owner != "" {
+				keys = append(keys, l.key(owner))
+			}
+		}
+	}
+
+	if len(keys) > 0 {
+		l.conf.Store.Delete(ctx, keys...)
+	}
+}
+
+func (l *List[T]) key(owner string) string {
+	return l.conf.Name + ":" + owner
+}
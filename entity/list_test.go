@@ -0,0 +1,84 @@
+package entity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestList(t *testing.T) {
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithCustomQueryHandler(queryAccounts)
+	e.Build()
+	defer e.Close()
+	typed := zeroentity.NewTyped[account](e)
+
+	// 数据库中的列表，4 已经不存在
+	members := []zeroentity.ListMember{{ID: 1, Score: 30}, {ID: 2, Score: 10}, {ID: 3, Score: 20}}
+	loads := 0
+
+	list := zeroentity.NewList(typed, zeroentity.ListConfig{
+		Name:  "accounts",
+		Store: zeroentitycache.NewMemoryListStore(),
+		Load: func(ctx context.Context, owner string) ([]zeroentity.ListMember, error) {
+			loads++
+			return members, nil
+		},
+		Desc: true,
+	})
+	ctx := context.Background()
+
+	page, err := list.Page(ctx, "zero", 1, 2)
+	if err != nil {
+		t.Fatalf("test Page failed: %s", err.Error())
+	}
+	if len(page) != 2 || page[0].UUID != 1 || page[1].UUID != 3 {
+		t.Fatalf("test Page failed, page: %+v", page)
+	}
+
+	page, _ = list.Page(ctx, "zero", 2, 2)
+	if len(page) != 1 || page[0].UUID != 2 {
+		t.Errorf("test Page failed, page: %+v", page)
+	}
+
+	// 超出范围的分页
+	if page, err := list.Page(ctx, "zero", 3, 2); err != nil || len(page) != 0 {
+		t.Errorf("test Page out of range failed, page: %+v, err: %v", page, err)
+	}
+
+	if n, _ := list.Count(ctx, "zero"); n != 3 || loads != 1 {
+		t.Errorf("test Count failed, count: %d, loads: %d", n, loads)
+	}
+
+	page, _ = list.RangeByScore(ctx, "zero", 15, 100, 0, 0)
+	if len(page) != 2 || page[0].UUID != 1 || page[1].UUID != 3 {
+		t.Errorf("test RangeByScore failed, page: %+v", page)
+	}
+
+	// 成员更新后，所在的列表被删除，重新加载
+	if err := typed.Update(&account{UUID: 2, Username: "zero2"}, 2); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	list.Count(ctx, "zero")
+	if loads != 2 {
+		t.Errorf("test list invalidation failed, loads: %d", loads)
+	}
+
+	// 列表中的数据已经不存在时，忽略并删除列表
+	members = append(members, zeroentity.ListMember{ID: 4, Score: 40})
+	list.Invalidate(ctx, "zero")
+
+	page, err = list.Page(ctx, "zero", 1, 10)
+	if err != nil {
+		t.Fatalf("test Page with missing member failed: %s", err.Error())
+	}
+	if len(page) != 3 {
+		t.Errorf("test Page with missing member failed, page: %+v", page)
+	}
+}
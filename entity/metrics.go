@@ -0,0 +1,274 @@
+package entity
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets 默认的耗时分桶上限，单位秒
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// ErrStatRegistered 同名统计已经注册
+var ErrStatRegistered = errors.New("stat already registered")
+
+// Histogram 耗时直方图，并发安全
+type Histogram struct {
+	// buckets 分桶上限，单位秒，升序
+	buckets []float64
+	// counts 每个分桶的次数，最后一个为 +Inf
+	counts []uint64
+	// sum 耗时总和，单位纳秒
+	sum uint64
+	// count 总次数
+	count uint64
+}
+
+// HistogramSnapshot 直方图快照
+type HistogramSnapshot struct {
+	// Buckets 分桶上限，单位秒
+	Buckets []float64
+	// Counts 小于等于对应上限的累计次数，与 Buckets 一一对应
+	Counts []uint64
+	// Count 总次数
+	Count uint64
+	// Sum 耗时总和，单位秒
+	Sum float64
+}
+
+// NewHistogram 创建一个直方图，buckets 为分桶上限，单位秒
+func NewHistogram(buckets []float64) *Histogram {
+	bs := make([]float64, len(buckets))
+	copy(bs, buckets)
+	sort.Float64s(bs)
+
+	return &Histogram{
+		buckets: bs,
+		counts:  make([]uint64, len(bs)+1),
+	}
+}
+
+// Observe 记录一次耗时
+func (h *Histogram) Observe(d time.Duration) {
+	idx := sort.SearchFloat64s(h.buckets, d.Seconds())
+	atomic.AddUint64(&h.counts[idx], 1)
+	atomic.AddUint64(&h.sum, uint64(d))
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Snapshot 获取直方图快照
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Buckets: h.buckets,
+		Counts:  make([]uint64, len(h.buckets)),
+		Count:   atomic.LoadUint64(&h.count),
+		Sum:     time.Duration(atomic.LoadUint64(&h.sum)).Seconds(),
+	}
+
+	var cumulative uint64
+	for idx := range h.buckets {
+		cumulative += atomic.LoadUint64(&h.counts[idx])
+		s.Counts[idx] = cumulative
+	}
+
+	return s
+}
+
+// Registry 统计注册表，按照 Prometheus 文本格式输出所有已注册的统计
+//
+// 实现了 http.Handler，可以直接挂载到 /metrics
+type Registry struct {
+	mu    sync.RWMutex
+	stats map[string]*Stat
+}
+
+// NewRegistry 创建一个统计注册表
+func NewRegistry() *Registry {
+	return &Registry{stats: make(map[string]*Stat)}
+}
+
+// Register 注册统计，名称即为输出中的 entity 标签
+func (r *Registry) Register(sts ...*Stat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, st := range sts {
+		if _, ok := r.stats[st.Name]; ok {
+			return ErrStatRegistered
+		}
+		r.stats[st.Name] = st
+	}
+
+	return nil
+}
+
+// Unregister 取消注册
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.stats, name)
+}
+
+// ServeHTTP 输出 Prometheus 文本格式
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo 按照 Prometheus 文本格式写入 w
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	snapshots := make([]StatSnapshot, 0, len(r.stats))
+	for _, st := range r.stats {
+		snapshots = append(snapshots, st.Snapshot())
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+
+	cw.header("entity_cache_hits_total", "counter", "Number of cache hits.")
+	for _, s := range snapshots {
+		cw.sample("entity_cache_hits_total", labels(s.Name, "tier", "local"), float64(s.LocalHit))
+		cw.sample("entity_cache_hits_total", labels(s.Name, "tier", "remote"), float64(s.RemoteHit))
+	}
+
+	cw.header("entity_cache_misses_total", "counter", "Number of cache misses.")
+	for _, s := range snapshots {
+		cw.sample("entity_cache_misses_total", labels(s.Name, "tier", "local"), float64(s.LocalMiss))
+		cw.sample("entity_cache_misses_total", labels(s.Name, "tier", "remote"), float64(s.RemoteMiss))
+	}
+
+	cw.header("entity_cache_hit_ratio", "gauge", "Cache hit ratio since start.")
+	for _, s := range snapshots {
+		cw.sample("entity_cache_hit_ratio", labels(s.Name, "tier", "local"), ratio(s.LocalHit, s.LocalMiss))
+		cw.sample("entity_cache_hit_ratio", labels(s.Name, "tier", "remote"), ratio(s.RemoteHit, s.RemoteMiss))
+	}
+
+	cw.header("entity_query_failures_total", "counter", "Number of failed database or custom queries.")
+	for _, s := range snapshots {
+		cw.sample("entity_query_failures_total", labels(s.Name, "source", "db"), float64(s.DBFails))
+		cw.sample("entity_query_failures_total", labels(s.Name, "source", "custom"), float64(s.CustomFails))
+	}
+
+	cw.header("entity_singleflight_shared_total", "counter", "Number of calls that shared the result of another in-flight call.")
+	for _, s := range snapshots {
+		cw.sample("entity_singleflight_shared_total", labels(s.Name), float64(s.Shared))
+	}
+
+	cw.header("entity_timeouts_total", "counter", "Number of calls that timed out.")
+	for _, s := range snapshots {
+		cw.sample("entity_timeouts_total", labels(s.Name), float64(s.Timeouts))
+	}
+
+	cw.header("entity_filter_rejections_total", "counter", "Number of ids rejected by the existence filter.")
+	for _, s := range snapshots {
+		cw.sample("entity_filter_rejections_total", labels(s.Name), float64(s.Filtered))
+	}
+
+	cw.header("entity_circuit_rejections_total", "counter", "Number of reads rejected because every read db was open.")
+	for _, s := range snapshots {
+		cw.sample("entity_circuit_rejections_total", labels(s.Name), float64(s.Rejected))
+	}
+
+	cw.header("entity_circuit_state", "gauge", "Circuit breaker state of each read db, 0 closed, 1 open, 2 half-open.")
+	for _, s := range snapshots {
+		for idx, state := range s.Breakers {
+			cw.sample("entity_circuit_state", labels(s.Name, "replica", strconv.Itoa(idx)), float64(state))
+		}
+	}
+
+	cw.header("entity_hot_key_count", "gauge", "Estimated sampled reads of the hottest ids in the current window.")
+	for _, s := range snapshots {
+		for _, hk := range s.HotKeys {
+			cw.sample("entity_hot_key_count", labels(s.Name, "id", strconv.FormatUint(hk.ID, 10)), float64(hk.Count))
+		}
+	}
+
+	cw.header("entity_latency_seconds", "histogram", "Latency of each tier in seconds.")
+	for _, s := range snapshots {
+		cw.histogram("entity_latency_seconds", s.Name, "local", s.LocalLatency)
+		cw.histogram("entity_latency_seconds", s.Name, "remote", s.RemoteLatency)
+		cw.histogram("entity_latency_seconds", s.Name, "db", s.DBLatency)
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+// countWriter 记录写入的字节数与第一个错误
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) write(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countWriter) header(name, typ, help string) {
+	cw.write("# HELP " + name + " " + help + "\n")
+	cw.write("# TYPE " + name + " " + typ + "\n")
+}
+
+func (cw *countWriter) sample(name, labels string, v float64) {
+	cw.write(name + "{" + labels + "} " + formatFloat(v) + "\n")
+}
+
+func (cw *countWriter) histogram(name, entity, tier string, s HistogramSnapshot) {
+	for idx, le := range s.Buckets {
+		cw.sample(name+"_bucket", labels(entity, "tier", tier, "le", formatFloat(le)), float64(s.Counts[idx]))
+	}
+	cw.sample(name+"_bucket", labels(entity, "tier", tier, "le", "+Inf"), float64(s.Count))
+	cw.sample(name+"_sum", labels(entity, "tier", tier), s.Sum)
+	cw.sample(name+"_count", labels(entity, "tier", tier), float64(s.Count))
+}
+
+// labels 生成标签，kvs 为 key, value 交替排列
+func labels(entity string, kvs ...string) string {
+	var sb strings.Builder
+	sb.WriteString(`entity="` + escapeLabel(entity) + `"`)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		sb.WriteString(`,` + kvs[i] + `="` + escapeLabel(kvs[i+1]) + `"`)
+	}
+	return sb.String()
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func ratio(hit, miss uint64) float64 {
+	if hit+miss == 0 {
+		return 0
+	}
+	return float64(hit) / float64(hit+miss)
+}
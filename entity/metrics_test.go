@@ -0,0 +1,94 @@
+package entity_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestHistogram(t *testing.T) {
+	h := zeroentity.NewHistogram([]float64{0.01, 0.1})
+	h.Observe(time.Millisecond)
+	h.Observe(50 * time.Millisecond)
+	h.Observe(time.Second)
+
+	s := h.Snapshot()
+	if s.Count != 3 || s.Counts[0] != 1 || s.Counts[1] != 2 {
+		t.Errorf("test Histogram failed, snapshot: %+v", s)
+	}
+	if s.Sum < 1.05 || s.Sum > 1.052 {
+		t.Errorf("test Histogram failed, sum: %f", s.Sum)
+	}
+}
+
+func TestZeroStat(t *testing.T) {
+	st := &zeroentity.Stat{Name: "zero"}
+
+	e := zeroentity.New(st, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithCustomQueryHandler(queryAccounts)
+	e.Build()
+	defer e.Close()
+
+	if _, err := zeroentity.NewTyped[account](e).Get(1); err != nil {
+		t.Fatalf("test Get with zero Stat failed: %s", err.Error())
+	}
+	if s := st.Snapshot(); s.LocalLatency.Count != 1 || s.DBLatency.Count != 1 {
+		t.Errorf("test Snapshot with zero Stat failed: %+v", s)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	st := zeroentity.NewStat("account", nil)
+
+	e := zeroentity.New(st, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithCustomQueryHandler(queryAccounts)
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[account](e)
+	for i := 0; i < 3; i++ {
+		if _, err := typed.Get(1); err != nil {
+			t.Fatalf("test Get failed: %s", err.Error())
+		}
+	}
+
+	s := st.Snapshot()
+	if s.LocalHit != 2 || s.LocalMiss != 1 || s.DBLatency.Count != 1 || s.LocalLatency.Count != 3 {
+		t.Errorf("test Snapshot failed: %+v", s)
+	}
+
+	registry := zeroentity.NewRegistry()
+	if err := registry.Register(st); err != nil {
+		t.Fatalf("test Register failed: %s", err.Error())
+	}
+	if err := registry.Register(st); err != zeroentity.ErrStatRegistered {
+		t.Errorf("test Register twice failed, err: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	for _, line := range []string{
+		"# TYPE entity_cache_hits_total counter",
+		`entity_cache_hits_total{entity="account",tier="local"} 2`,
+		`entity_cache_misses_total{entity="account",tier="local"} 1`,
+		`entity_cache_hit_ratio{entity="account",tier="local"} 0.6666666666666666`,
+		`entity_latency_seconds_bucket{entity="account",tier="db",le="+Inf"} 1`,
+		`entity_latency_seconds_count{entity="account",tier="local"} 3`,
+		`entity_timeouts_total{entity="account"} 0`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("test ServeHTTP failed, missing: %s", line)
+		}
+	}
+}
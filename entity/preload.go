@@ -0,0 +1,186 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// defaultPreloadBatchSize 预热时每批加载的默认数量
+const defaultPreloadBatchSize = 500
+
+// IDSource 预热的主键来源，每次最多返回 limit 个主键，返回空时表示结束
+type IDSource func(ctx context.Context, limit int) ([]uint64, error)
+
+// RangeIDs 主键范围 [start, end)
+func RangeIDs(start, end uint64) IDSource {
+	next := start
+
+	return func(ctx context.Context, limit int) ([]uint64, error) {
+		ids := make([]uint64, 0, limit)
+		for next < end && len(ids) < limit {
+			ids = append(ids, next)
+			next++
+		}
+		return ids, nil
+	}
+}
+
+// SliceIDs 指定的主键集合
+func SliceIDs(ids []uint64) IDSource {
+	offset := 0
+
+	return func(ctx context.Context, limit int) ([]uint64, error) {
+		end := offset + limit
+		if end > len(ids) {
+			end = len(ids)
+		}
+		part := ids[offset:end]
+		offset = end
+		return part, nil
+	}
+}
+
+// ScanIDs 按照主键升序扫描数据库中的所有主键，model 含义同 IDScanner.ScanIDs
+// db 未实现 IDScanner 时返回 ErrNoIDScanner
+func ScanIDs(db WrapReadDB, model interface{}) IDSource {
+	var afterID uint64
+
+	return func(ctx context.Context, limit int) ([]uint64, error) {
+		scanner, ok := db.(IDScanner)
+		if !ok {
+			return nil, ErrNoIDScanner
+		}
+
+		ids, err := scanner.ScanIDsCtx(ctx, model, afterID, limit)
+		if err != nil || len(ids) == 0 {
+			return nil, err
+		}
+		afterID = ids[len(ids)-1]
+		return ids, nil
+	}
+}
+
+// PreloadConfig 预热配置
+type PreloadConfig struct {
+	// BatchSize 每批加载的数量，默认 500
+	BatchSize int
+
+	// Rate 每秒最多加载的数量，0 表示不限制
+	Rate int
+
+	// OnProgress 每批加载完成后调用，可选
+	OnProgress func(p PreloadProgress)
+}
+
+// PreloadProgress 预热进度
+type PreloadProgress struct {
+	// Batches 已完成的批次
+	Batches int
+	// Total 已处理的主键数量
+	Total int
+	// Found 已存在的数据数量，包括原本就在缓存中的数据
+	Found int
+	// Missing 不存在的数据数量
+	Missing int
+	// Elapsed 已耗时
+	Elapsed time.Duration
+}
+
+// Preload 预热缓存，从 source 中分批读取主键，通过批量查询写入本地缓存与远端缓存，远端缓存命中的数据也会写入本地缓存
+//
+// out 为切片指针，同 MGet，如 *[]Account，每批使用一个新的切片
+// 不受 WithTimeout 影响，通过 ctx 控制超时与取消
+func (e *entity) Preload(ctx context.Context, out interface{}, source IDSource, conf PreloadConfig) (PreloadProgress, error) {
+	var p PreloadProgress
+
+	outType := reflect.TypeOf(out)
+	if outType == nil || outType.Kind() != reflect.Ptr || outType.Elem().Kind() != reflect.Slice {
+		return p, errors.New("out must be a pointer to slice")
+	}
+
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultPreloadBatchSize
+	}
+
+	start := time.Now()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return p, err
+		}
+
+		ids, err := source(ctx, conf.BatchSize)
+		if err != nil {
+			return p, err
+		}
+		if len(ids) == 0 {
+			return p, nil
+		}
+
+		batch := reflect.New(outType.Elem()).Interface()
+		result, err := e.mget(ctx, batch, genSingleFlightKeyMulti(ids...), ids, true, true)
+		if err != nil {
+			return p, err
+		}
+
+		p.Batches++
+		p.Total += len(ids)
+		for _, val := range result.Vals {
+			if IsEmptyPlaceholder(val) {
+				p.Missing++
+			} else {
+				p.Found++
+			}
+		}
+		p.Elapsed = time.Since(start)
+
+		if conf.OnProgress != nil {
+			conf.OnProgress(p)
+		}
+
+		// 限速，按照已处理的数量计算应耗费的时间
+		if conf.Rate > 0 {
+			wait := time.Duration(p.Total)*time.Second/time.Duration(conf.Rate) - p.Elapsed
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return p, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+	}
+}
+
+// fillLocalCache 将远端缓存命中的数据写入本地缓存，未找到的占位符使用短期有效期
+func (e *entity) fillLocalCache(ctx context.Context, ids []uint64, datas [][]byte) {
+	if e.localCache == nil || len(ids) == 0 {
+		return
+	}
+
+	localIDs := make([]uint64, 0, len(ids))
+	localDatas := make([][]byte, 0, len(ids))
+	localTTLs := make([]time.Duration, 0, len(ids))
+	for idx, id := range ids {
+		if !e.keepLocal(id) {
+			continue
+		}
+
+		ttl := e.expire(e.ttl)
+		if IsEmptyPlaceholder(datas[idx]) {
+			ttl = e.expire(e.notFoundExpired)
+		}
+		localIDs = append(localIDs, id)
+		localDatas = append(localDatas, datas[idx])
+		localTTLs = append(localTTLs, ttl)
+	}
+
+	if len(localIDs) == 0 {
+		return
+	}
+	if err := e.localCache.MSetExCtx(ctx, localIDs, localDatas, localTTLs); err != nil {
+		e.logger.Errorf("set local cache failed, ids: %v, err: %s", localIDs, err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package entity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	"github.com/zerogo-hub/zero-helper/entity/entitytest"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestPreload(t *testing.T) {
+	local := zeroentitycache.NewBigCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithCustomQueryHandler(queryAccounts)
+	e.Build()
+	defer e.Close()
+
+	batches := 0
+	p, err := e.Preload(context.Background(), &[]account{}, zeroentity.RangeIDs(1, 6), zeroentity.PreloadConfig{
+		BatchSize:  2,
+		Rate:       100,
+		OnProgress: func(p zeroentity.PreloadProgress) { batches++ },
+	})
+	if err != nil {
+		t.Fatalf("test Preload failed: %s", err.Error())
+	}
+	if p.Batches != 3 || batches != 3 || p.Total != 5 || p.Found != 3 || p.Missing != 2 {
+		t.Errorf("test Preload failed, progress: %+v", p)
+	}
+	// 限速 100/s，处理第三批时至少已经过了 40ms
+	if p.Elapsed < 40*time.Millisecond {
+		t.Errorf("test Preload rate failed, elapsed: %s", p.Elapsed)
+	}
+
+	for id := uint64(1); id <= 3; id++ {
+		bs, err := local.Get(id)
+		if err != nil || zeroentity.IsEmptyPlaceholder(bs) {
+			t.Errorf("test Preload failed, id: %d not cached", id)
+		}
+	}
+	if bs, err := local.Get(4); err != nil || !zeroentity.IsEmptyPlaceholder(bs) {
+		t.Errorf("test Preload failed, id: 4 expected placeholder")
+	}
+
+	p, err = e.Preload(context.Background(), &[]account{}, zeroentity.SliceIDs([]uint64{2, 3}), zeroentity.PreloadConfig{})
+	if err != nil || p.Found != 2 {
+		t.Errorf("test Preload with slice failed, progress: %+v, err: %v", p, err)
+	}
+}
+
+func TestPreloadRemoteHit(t *testing.T) {
+	remote := entitytest.NewCache(time.Minute)
+
+	warm := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	warm.WithRemoteCache(remote)
+	warm.WithCustomQueryHandler(queryAccounts)
+	warm.Build()
+	defer warm.Close()
+
+	if _, err := warm.Preload(context.Background(), &[]account{}, zeroentity.RangeIDs(1, 4), zeroentity.PreloadConfig{}); err != nil {
+		t.Fatalf("test Preload failed: %s", err.Error())
+	}
+
+	// 远端缓存已预热，命中的数据写入本地缓存
+	local := entitytest.NewCache(time.Minute)
+	queries := 0
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithRemoteCache(remote)
+	e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+		queries++
+		return queryAccounts(out, ids...)
+	})
+	e.Build()
+	defer e.Close()
+
+	p, err := e.Preload(context.Background(), &[]account{}, zeroentity.RangeIDs(1, 4), zeroentity.PreloadConfig{})
+	if err != nil || p.Found != 3 || queries != 0 {
+		t.Errorf("test Preload with remote hit failed, progress: %+v, queries: %d, err: %v", p, queries, err)
+	}
+	if n := local.Len(); n != 3 {
+		t.Errorf("test Preload with remote hit failed, local cache: %d", n)
+	}
+}
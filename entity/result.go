@@ -2,6 +2,8 @@ package entity
 
 // Results 查询结果
 type Result struct {
+	// IDs 与 Vals 一一对应的主键
+	IDs  []uint64
 	Vals [][]byte
 	Errs []error
 }
@@ -16,7 +18,7 @@ func (r *Result) Index(idx int) ([]byte, error) {
 		return nil, ErrResultIndexInvalid
 	}
 
-	if r.Errs[idx] != nil {
+	if idx < len(r.Errs) && r.Errs[idx] != nil {
 		return nil, r.Errs[idx]
 	}
 
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"errors"
+)
+
+// ErrSchemaMismatch 缓存中的数据与当前的数据结构版本或者编码不一致，且没有对应的升级函数
+var ErrSchemaMismatch = errors.New("schema mismatch")
+
+// UpgradeHandler 升级函数，将旧版本的数据解码到当前版本的 out 中
+// codec 为写入时使用的编码名称，旧数据为空
+type UpgradeHandler func(codec string, in []byte, out interface{}) error
+
+// WithSchemaVersion 设置数据结构版本，修改结构体时递增
+//
+// 设置后缓存值会带上版本与编码名称，读取到版本或者编码不一致的数据时，
+// 存在对应的升级函数则通过升级函数解码，否则视为未命中，从数据库中重新加载
+func (e *entity) WithSchemaVersion(version uint16) Entity {
+	e.schema = version
+	e.versioned = true
+	return e
+}
+
+// WithUpgrade 注册升级函数，version 为缓存中数据的版本，未设置版本的旧数据为 0
+func (e *entity) WithUpgrade(version uint16, handler UpgradeHandler) Entity {
+	if e.upgrades == nil {
+		e.upgrades = make(map[uint16]UpgradeHandler)
+	}
+	e.upgrades[version] = handler
+	e.versioned = true
+	return e
+}
+
+// mismatch 数据的版本或者编码是否与当前不一致
+func (e *entity) mismatch(env *envelope) bool {
+	if env.codec != "" && env.codec != e.codec.Name() {
+		return true
+	}
+	return e.versioned && env.schema != e.schema
+}
+
+// compatible 缓存中的数据是否可以使用，墓碑或者不可以解码时视为未命中
+func (e *entity) compatible(bs []byte) bool {
+	if IsEmptyPlaceholder(bs) {
+		return true
+	}
+
+	if _, ok := decodeTombstone(bs); ok {
+		return false
+	}
+
+	env, _ := unwrapEnvelope(bs)
+
+	// 已压缩但未设置压缩方式
+	if env.flags&flagCompressed != 0 && e.compress == nil {
+		return false
+	}
+
+	if !e.mismatch(env) {
+		return true
+	}
+
+	_, ok := e.upgrades[env.schema]
+	return ok
+}
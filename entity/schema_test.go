@@ -0,0 +1,78 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	// 多个版本的实例共用同一个缓存，模拟滚动发布
+	cache := zeroentitycache.NewBigCache(time.Minute)
+	queries := 0
+
+	newEntity := func() zeroentity.Entity {
+		e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+		e.WithLocalCache(cache)
+		e.WithCustomQueryHandler(func(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+			queries++
+			return queryAccounts(out, ids...)
+		})
+		return e
+	}
+
+	// 未设置版本的旧数据
+	e0 := newEntity()
+	e0.Build()
+	defer e0.Close()
+	if _, err := zeroentity.NewTyped[account](e0).Get(1); err != nil {
+		t.Fatalf("test Get failed: %s", err.Error())
+	}
+
+	// 版本 1 可以升级旧数据
+	e1 := newEntity()
+	e1.WithSchemaVersion(1)
+	e1.WithUpgrade(0, func(codec string, in []byte, out interface{}) error {
+		if err := zerocmsgpack.New().Unmarshal(in, out); err != nil {
+			return err
+		}
+		a := out.(*account)
+		a.Username = strings.ToUpper(a.Username)
+		return nil
+	})
+	e1.Build()
+	defer e1.Close()
+
+	a, err := zeroentity.NewTyped[account](e1).Get(1)
+	if err != nil || a.Username != "ZERO1" || queries != 1 {
+		t.Errorf("test upgrade failed, account: %v, queries: %d, err: %v", a, queries, err)
+	}
+
+	// 版本 2 没有升级函数，视为未命中，重新加载
+	e2 := newEntity()
+	e2.WithSchemaVersion(2)
+	e2.Build()
+	defer e2.Close()
+
+	a, err = zeroentity.NewTyped[account](e2).Get(1)
+	if err != nil || a.Username != "zero1" || queries != 2 {
+		t.Errorf("test discard failed, account: %v, queries: %d, err: %v", a, queries, err)
+	}
+
+	// 版本 2 写入的数据，版本 2 直接命中
+	a, err = zeroentity.NewTyped[account](e2).Get(1)
+	if err != nil || queries != 2 {
+		t.Errorf("test Get failed, queries: %d, err: %v", queries, err)
+	}
+
+	// 版本 1 读取版本 2 的数据，同样视为未命中
+	list, err := zeroentity.NewTyped[account](e1).MGet(1)
+	if err != nil || len(list) != 1 || queries != 3 {
+		t.Errorf("test MGet discard failed, list: %v, queries: %d, err: %v", list, queries, err)
+	}
+}
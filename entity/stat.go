@@ -1,14 +1,17 @@
 package entity
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// StatHandler 统计处理函数
+// StatHandler 统计处理函数，参数为上一个周期内的增量
 type StatHandler func(localHit, localMiss, remoteHit, remoteMiss, dbFails, customFails uint64)
 
 // Stat 统计
+//
+// 计数器只增不减，StatHandler 每分钟收到一次增量，Registry 拉取累计值
 type Stat struct {
 	Name string
 
@@ -28,19 +31,125 @@ type Stat struct {
 	// customHandlerFail 自定义查询失败次数
 	customHandlerFail uint64
 
+	// shared 共享了其它请求查询结果的次数 (singleflight)
+	shared uint64
+
+	// timeout 查询超时次数
+	timeout uint64
+
+	// filtered 被存在性过滤器拦截的次数
+	filtered uint64
+
+	// circuitRejected 读数据库全部熔断而快速失败的次数
+	circuitRejected uint64
+
+	// latencyOnce 首次使用时创建耗时直方图，零值的 Stat 也可以使用
+	latencyOnce sync.Once
+	// localLatency 本地缓存查询耗时
+	localLatency *Histogram
+	// remoteLatency 远端缓存查询耗时
+	remoteLatency *Histogram
+	// dbLatency 数据库或者自定义查询耗时
+	dbLatency *Histogram
+
+	// hotKeys 获取热点主键，开启 WithHotKey 后由实体设置
+	hotKeys atomic.Value
+
+	// breakers 获取熔断状态，开启 WithCircuitBreaker 后由实体设置
+	breakers atomic.Value
+
 	handler StatHandler
 }
 
-// NewStat 创建一个统计对象
+// StatSnapshot 统计快照，均为累计值
+type StatSnapshot struct {
+	Name string
 
+	LocalHit    uint64
+	LocalMiss   uint64
+	RemoteHit   uint64
+	RemoteMiss  uint64
+	DBFails     uint64
+	CustomFails uint64
+	Shared      uint64
+	Timeouts    uint64
+	Filtered    uint64
+	Rejected    uint64
+
+	LocalLatency  HistogramSnapshot
+	RemoteLatency HistogramSnapshot
+	DBLatency     HistogramSnapshot
+
+	// HotKeys 热点主键，按照计数降序
+	HotKeys []HotKey
+
+	// Breakers 每个读数据库的熔断状态
+	Breakers []BreakerState
+}
+
+// NewStat 创建一个统计对象
+// handler 可以为 nil，此时仅通过 Snapshot 或者 Registry 获取统计
 func NewStat(name string, handler StatHandler) *Stat {
-	st := &Stat{Name: name, handler: handler}
+	st := &Stat{
+		Name:    name,
+		handler: handler,
+	}
 	if handler != nil {
 		go st.loop()
 	}
 	return st
 }
 
+// Snapshot 获取当前的累计统计
+func (st *Stat) Snapshot() StatSnapshot {
+	st.latencyOnce.Do(st.initLatency)
+
+	return StatSnapshot{
+		Name:          st.Name,
+		LocalHit:      atomic.LoadUint64(&st.localCacheHit),
+		LocalMiss:     atomic.LoadUint64(&st.localCacheMiss),
+		RemoteHit:     atomic.LoadUint64(&st.remoteCacheHit),
+		RemoteMiss:    atomic.LoadUint64(&st.remoteCacheMiss),
+		DBFails:       atomic.LoadUint64(&st.dbFail),
+		CustomFails:   atomic.LoadUint64(&st.customHandlerFail),
+		Shared:        atomic.LoadUint64(&st.shared),
+		Timeouts:      atomic.LoadUint64(&st.timeout),
+		Filtered:      atomic.LoadUint64(&st.filtered),
+		Rejected:      atomic.LoadUint64(&st.circuitRejected),
+		LocalLatency:  st.localLatency.Snapshot(),
+		RemoteLatency: st.remoteLatency.Snapshot(),
+		DBLatency:     st.dbLatency.Snapshot(),
+		HotKeys:       st.HotKeys(),
+		Breakers:      st.Breakers(),
+	}
+}
+
+// Breakers 每个读数据库的熔断状态，未开启熔断时返回 nil
+func (st *Stat) Breakers() []BreakerState {
+	if f, ok := st.breakers.Load().(func() []BreakerState); ok {
+		return f()
+	}
+	return nil
+}
+
+// setBreakers 设置熔断状态来源
+func (st *Stat) setBreakers(f func() []BreakerState) {
+	st.breakers.Store(f)
+}
+
+// HotKeys 热点主键，未开启热点主键探测时返回 nil
+func (st *Stat) HotKeys() []HotKey {
+	if f, ok := st.hotKeys.Load().(func() []HotKey); ok {
+		return f()
+	}
+	return nil
+}
+
+// setHotKeys 设置热点主键来源
+func (st *Stat) setHotKeys(f func() []HotKey) {
+	st.hotKeys.Store(f)
+}
+
 // incLocalCacheHit 增加本地缓存命中次数
 func (st *Stat) incLocalCacheHit() {
 	atomic.AddUint64(&st.localCacheHit, 1)
@@ -71,22 +180,70 @@ func (st *Stat) incCustomHandlerFail() {
 	atomic.AddUint64(&st.customHandlerFail, 1)
 }
 
+// incShared 增加共享查询结果次数
+func (st *Stat) incShared() {
+	atomic.AddUint64(&st.shared, 1)
+}
+
+// incTimeout 增加查询超时次数
+func (st *Stat) incTimeout() {
+	atomic.AddUint64(&st.timeout, 1)
+}
+
+// incFiltered 增加被存在性过滤器拦截的次数
+func (st *Stat) incFiltered() {
+	atomic.AddUint64(&st.filtered, 1)
+}
+
+// incCircuitRejected 增加熔断快速失败次数
+func (st *Stat) incCircuitRejected() {
+	atomic.AddUint64(&st.circuitRejected, 1)
+}
+
+// initLatency 创建耗时直方图，由 latencyOnce 调用
+func (st *Stat) initLatency() {
+	st.localLatency = NewHistogram(DefaultBuckets)
+	st.remoteLatency = NewHistogram(DefaultBuckets)
+	st.dbLatency = NewHistogram(DefaultBuckets)
+}
+
+// observeLocal 记录本地缓存查询耗时
+func (st *Stat) observeLocal(start time.Time) {
+	st.latencyOnce.Do(st.initLatency)
+	st.localLatency.Observe(time.Since(start))
+}
+
+// observeRemote 记录远端缓存查询耗时
+func (st *Stat) observeRemote(start time.Time) {
+	st.latencyOnce.Do(st.initLatency)
+	st.remoteLatency.Observe(time.Since(start))
+}
+
+// observeDB 记录数据库或者自定义查询耗时
+func (st *Stat) observeDB(start time.Time) {
+	st.latencyOnce.Do(st.initLatency)
+	st.dbLatency.Observe(time.Since(start))
+}
+
 // loop 进行一些统计计算
 func (st *Stat) loop() {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		localHit := atomic.SwapUint64(&st.localCacheHit, 0)
-		localMiss := atomic.SwapUint64(&st.localCacheMiss, 0)
-
-		remoteHit := atomic.SwapUint64(&st.remoteCacheHit, 0)
-		remoteMiss := atomic.SwapUint64(&st.remoteCacheMiss, 0)
-
-		dbf := atomic.SwapUint64(&st.dbFail, 0)
+	last := st.Snapshot()
 
-		chf := atomic.SwapUint64(&st.customHandlerFail, 0)
-
-		st.handler(localHit, localMiss, remoteHit, remoteMiss, dbf, chf)
+	for range ticker.C {
+		cur := st.Snapshot()
+
+		st.handler(
+			cur.LocalHit-last.LocalHit,
+			cur.LocalMiss-last.LocalMiss,
+			cur.RemoteHit-last.RemoteHit,
+			cur.RemoteMiss-last.RemoteMiss,
+			cur.DBFails-last.DBFails,
+			cur.CustomFails-last.CustomFails,
+		)
+
+		last = cur
 	}
 }
@@ -0,0 +1,179 @@
+package entity
+
+import (
+	"context"
+)
+
+// Typed 泛型实体，基于 Entity，直接返回 T，无需手动解码
+//
+// T 一般为结构体类型，如 Account，而不是 *Account
+type Typed[T any] struct {
+	e Entity
+}
+
+// NewTyped 创建一个泛型实体
+//
+// 内部复用 e 的 singleflight、本地缓存、远端缓存以及编码解码器
+func NewTyped[T any](e Entity) *Typed[T] {
+	return &Typed[T]{e: e}
+}
+
+// Entity 返回内部的实体管理器
+func (t *Typed[T]) Entity() Entity {
+	return t.e
+}
+
+// Get 根据主键获取数据
+func (t *Typed[T]) Get(id uint64) (T, error) {
+	return t.GetCtx(context.Background(), id)
+}
+
+// GetCtx 根据主键获取数据
+func (t *Typed[T]) GetCtx(ctx context.Context, id uint64) (T, error) {
+	var out T
+	err := t.e.GetCtx(ctx, &out, id)
+	return out, err
+}
+
+// GetWithQuery 根据主键获取数据
+// query 自定义查询
+func (t *Typed[T]) GetWithQuery(id uint64, query QueryHandler) (T, error) {
+	var out T
+	err := t.e.GetWithQuery(&out, id, query)
+	return out, err
+}
+
+// GetByKey 根据二级键获取数据
+func (t *Typed[T]) GetByKey(index, key string) (T, error) {
+	return t.GetByKeyCtx(context.Background(), index, key)
+}
+
+// GetByKeyCtx 根据二级键获取数据
+func (t *Typed[T]) GetByKeyCtx(ctx context.Context, index, key string) (T, error) {
+	var out T
+	err := t.e.GetByKeyCtx(ctx, &out, index, key)
+	return out, err
+}
+
+// partialGetter 批量获取时，未找到的数据以占位符返回，而不是返回 ErrSomeNotFound
+type partialGetter interface {
+	mgetPartial(ctx context.Context, out interface{}, ids ...uint64) (*Result, error)
+}
+
+// MGet 根据主键批量获取数据，结果顺序与 ids 一致
+// 不存在的数据会被忽略，无论是否已经缓存
+func (t *Typed[T]) MGet(ids ...uint64) ([]T, error) {
+	return t.MGetCtx(context.Background(), ids...)
+}
+
+// MGetCtx 根据主键批量获取数据，结果顺序与 ids 一致
+// 不存在的数据会被忽略，无论是否已经缓存
+func (t *Typed[T]) MGetCtx(ctx context.Context, ids ...uint64) ([]T, error) {
+	m, err := t.MGetMapCtx(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]T, 0, len(m))
+	for _, id := range ids {
+		if v, ok := m[id]; ok {
+			results = append(results, v)
+		}
+	}
+
+	return results, nil
+}
+
+// MGetMap 根据主键批量获取数据，返回 主键 -> 数据，不包含不存在的数据
+func (t *Typed[T]) MGetMap(ids ...uint64) (map[uint64]T, error) {
+	return t.MGetMapCtx(context.Background(), ids...)
+}
+
+// MGetMapCtx 根据主键批量获取数据，返回 主键 -> 数据，不包含不存在的数据
+//
+// Entity 为自定义实现时，使用其 MGetCtx，此时可能返回 ErrSomeNotFound
+func (t *Typed[T]) MGetMapCtx(ctx context.Context, ids ...uint64) (map[uint64]T, error) {
+	var (
+		outs   []T
+		result *Result
+		err    error
+	)
+	if pg, ok := t.e.(partialGetter); ok {
+		result, err = pg.mgetPartial(ctx, &outs, ids...)
+	} else {
+		result, err = t.e.MGetCtx(ctx, &outs, ids...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t.decode(result)
+}
+
+// Set 缓存数据
+func (t *Typed[T]) Set(in T, id uint64) error {
+	return t.e.Set(&in, id)
+}
+
+// Update 更新数据库，更新缓存
+func (t *Typed[T]) Update(in *T, id uint64) error {
+	return t.e.Update(in, id)
+}
+
+// UpdateCtx 更新数据库，更新缓存
+func (t *Typed[T]) UpdateCtx(ctx context.Context, in *T, id uint64) error {
+	return t.e.UpdateCtx(ctx, in, id)
+}
+
+// Delete 删除数据库，删除缓存
+func (t *Typed[T]) Delete(id uint64) error {
+	return t.e.Delete(new(T), id)
+}
+
+// DeleteCtx 删除数据库，删除缓存
+func (t *Typed[T]) DeleteCtx(ctx context.Context, id uint64) error {
+	return t.e.DeleteCtx(ctx, new(T), id)
+}
+
+// MDelete 批量删除数据库，删除缓存
+func (t *Typed[T]) MDelete(ids ...uint64) error {
+	return t.e.MDelete(new(T), ids...)
+}
+
+// MDeleteCtx 批量删除数据库，删除缓存
+func (t *Typed[T]) MDeleteCtx(ctx context.Context, ids ...uint64) error {
+	return t.e.MDeleteCtx(ctx, new(T), ids...)
+}
+
+// RemoveCache 仅删除缓存
+func (t *Typed[T]) RemoveCache(id uint64) {
+	t.e.RemoveCache(id)
+}
+
+// decode 将批量查询结果解码为 主键 -> 数据
+func (t *Typed[T]) decode(result *Result) (map[uint64]T, error) {
+	if len(result.IDs) != result.Len() {
+		return nil, ErrResultIdNotFound
+	}
+
+	m := make(map[uint64]T, result.Len())
+	for idx, id := range result.IDs {
+		bs, err := result.Index(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		// 未命中而设置的短期缓存
+		if IsEmptyPlaceholder(bs) {
+			continue
+		}
+
+		var out T
+		if err := t.e.Unmarshal(bs, &out); err != nil {
+			return nil, err
+		}
+		m[id] = out
+	}
+
+	return m, nil
+}
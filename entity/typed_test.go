@@ -0,0 +1,114 @@
+package entity_test
+
+import (
+	"testing"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+type account struct {
+	UUID     uint64
+	Username string
+}
+
+var accounts = map[uint64]account{
+	1: {UUID: 1, Username: "zero1"},
+	2: {UUID: 2, Username: "zero2"},
+	3: {UUID: 3, Username: "zero3"},
+}
+
+func queryAccounts(out interface{}, ids ...uint64) ([]uint64, []interface{}, error) {
+	loadedIDs := make([]uint64, 0, len(ids))
+	datas := make([]interface{}, 0, len(ids))
+
+	for _, id := range ids {
+		a, ok := accounts[id]
+		if !ok {
+			continue
+		}
+		loadedIDs = append(loadedIDs, id)
+		datas = append(datas, a)
+	}
+
+	if len(loadedIDs) == 0 {
+		return nil, nil, zeroentity.ErrNotFound
+	}
+
+	if o, ok := out.(*account); ok {
+		*o = datas[0].(account)
+	}
+
+	return loadedIDs, datas, nil
+}
+
+func newTypedAccount() *zeroentity.Typed[account] {
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithCustomQueryHandler(queryAccounts)
+	e.Build()
+
+	return zeroentity.NewTyped[account](e)
+}
+
+func TestTypedGet(t *testing.T) {
+	typed := newTypedAccount()
+
+	a, err := typed.Get(2)
+	if err != nil {
+		t.Fatalf("test Get failed: %s", err.Error())
+	}
+	if a.Username != "zero2" {
+		t.Errorf("test Get failed, username: %s", a.Username)
+	}
+
+	if _, err := typed.Get(100); err == nil {
+		t.Error("test Get failed, expected error")
+	}
+}
+
+func TestTypedMGet(t *testing.T) {
+	typed := newTypedAccount()
+
+	list, err := typed.MGet(3, 1, 2)
+	if err != nil {
+		t.Fatalf("test MGet failed: %s", err.Error())
+	}
+	if len(list) != 3 {
+		t.Fatalf("test MGet failed, len: %d", len(list))
+	}
+	if list[0].UUID != 3 || list[1].UUID != 1 || list[2].UUID != 2 {
+		t.Errorf("test MGet failed, invalid order: %v", list)
+	}
+
+	m, err := typed.MGetMap(1, 2)
+	if err != nil {
+		t.Fatalf("test MGetMap failed: %s", err.Error())
+	}
+	if len(m) != 2 || m[1].Username != "zero1" || m[2].Username != "zero2" {
+		t.Errorf("test MGetMap failed: %v", m)
+	}
+}
+
+func TestTypedMGetMissing(t *testing.T) {
+	typed := newTypedAccount()
+
+	// 缓存为空与已缓存占位符时，结果一致
+	for i := 0; i < 2; i++ {
+		list, err := typed.MGet(1, 100, 2)
+		if err != nil {
+			t.Fatalf("test MGet with missing id failed, round: %d, err: %s", i, err.Error())
+		}
+		if len(list) != 2 || list[0].UUID != 1 || list[1].UUID != 2 {
+			t.Errorf("test MGet with missing id failed, round: %d, list: %v", i, list)
+		}
+	}
+
+	m, err := typed.MGetMap(3, 200)
+	if err != nil {
+		t.Fatalf("test MGetMap with missing id failed: %s", err.Error())
+	}
+	if len(m) != 1 || m[3].Username != "zero3" {
+		t.Errorf("test MGetMap with missing id failed: %v", m)
+	}
+}
@@ -0,0 +1,106 @@
+package entity
+
+import (
+	"context"
+	"sync"
+)
+
+// uowKey 在 ctx 中存放 UnitOfWork 的键
+type uowKey struct{}
+
+// UnitOfWork 收集事务中多个实体的缓存失效，事务提交后统一删除缓存
+//
+// ctx 中携带 UnitOfWork 时，Update、Delete、MDelete 依旧同步写入数据库 (包括 write-behind 模式)，
+// 但不会立即删除缓存，而是记录下来，由 Commit 按照失效策略删除缓存，Rollback 丢弃
+type UnitOfWork struct {
+	mu    sync.Mutex
+	items []invalidation
+	done  bool
+}
+
+// invalidation 等待执行的缓存失效
+type invalidation struct {
+	e     Entity
+	model interface{}
+	ids   []uint64
+	// deleted 由 Delete、MDelete 记录，提交时从存在性过滤器中删除
+	deleted bool
+}
+
+// NewUnitOfWork 创建一个 UnitOfWork
+func NewUnitOfWork() *UnitOfWork {
+	return &UnitOfWork{}
+}
+
+// ContextWithUnitOfWork 将 u 放入 ctx 中
+func ContextWithUnitOfWork(ctx context.Context, u *UnitOfWork) context.Context {
+	return context.WithValue(ctx, uowKey{}, u)
+}
+
+// UnitOfWorkFromContext 从 ctx 中取出 UnitOfWork，不存在时返回 nil
+func UnitOfWorkFromContext(ctx context.Context) *UnitOfWork {
+	u, _ := ctx.Value(uowKey{}).(*UnitOfWork)
+	return u
+}
+
+// Add 记录一次缓存失效，model 用于删除二级键的映射，可以为 nil
+func (u *UnitOfWork) Add(e Entity, model interface{}, ids ...uint64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.items = append(u.items, invalidation{e: e, model: model, ids: ids})
+}
+
+// Commit 事务提交后调用，删除记录的所有缓存，只会执行一次
+func (u *UnitOfWork) Commit(ctx context.Context) {
+	u.mu.Lock()
+	if u.done {
+		u.mu.Unlock()
+		return
+	}
+	u.done = true
+	items := u.items
+	u.items = nil
+	u.mu.Unlock()
+
+	for _, item := range items {
+		if item.deleted {
+			item.e.(*entity).unmarkExist(item.ids...)
+		}
+		item.e.Invalidate(ctx, item.model, item.ids...)
+	}
+}
+
+// Rollback 事务回滚后调用，丢弃记录的缓存失效
+func (u *UnitOfWork) Rollback() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.done = true
+	u.items = nil
+}
+
+// Invalidate 按照失效策略删除缓存 (见 WithInvalidation)，并删除 model 对应的二级键映射，model 可以为 nil
+func (e *entity) Invalidate(ctx context.Context, model interface{}, ids ...uint64) {
+	e.invalidateCache(ctx, ids...)
+
+	if model != nil {
+		e.invalidateKeys(ctx, model)
+	}
+
+	e.runInvalidateHooks(ctx, model, ids...)
+}
+
+// deferInvalidate ctx 中携带 UnitOfWork 时，记录删除数据后的缓存失效并返回 true
+func (e *entity) deferInvalidate(ctx context.Context, model interface{}, ids ...uint64) bool {
+	u := UnitOfWorkFromContext(ctx)
+	if u == nil {
+		return false
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.items = append(u.items, invalidation{e: e, model: model, ids: ids, deleted: true})
+	return true
+}
@@ -0,0 +1,66 @@
+package entity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	newEntity := func() (zeroentity.Entity, zeroentity.WrapCache) {
+		local := zeroentitycache.NewBigCache(time.Minute)
+		e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+		e.WithLocalCache(local)
+		e.WithCustomUpdateHandler(func(out interface{}, id ...uint64) error { return nil })
+		e.Build()
+		return e, local
+	}
+
+	e1, local1 := newEntity()
+	defer e1.Close()
+	e2, local2 := newEntity()
+	defer e2.Close()
+
+	e1.Set(&account{UUID: 1, Username: "zero1"}, 1)
+	e2.Set(&account{UUID: 2, Username: "zero2"}, 2)
+
+	u := zeroentity.NewUnitOfWork()
+	ctx := zeroentity.ContextWithUnitOfWork(context.Background(), u)
+
+	if err := e1.UpdateCtx(ctx, &account{UUID: 1, Username: "one"}, 1); err != nil {
+		t.Fatalf("test UpdateCtx failed: %s", err.Error())
+	}
+	if err := e2.UpdateCtx(ctx, &account{UUID: 2, Username: "two"}, 2); err != nil {
+		t.Fatalf("test UpdateCtx failed: %s", err.Error())
+	}
+
+	if _, err := local1.Get(1); err != nil {
+		t.Errorf("test UnitOfWork failed, cache removed before commit: %s", err.Error())
+	}
+
+	u.Commit(context.Background())
+
+	if _, err := local1.Get(1); err == nil {
+		t.Error("test UnitOfWork failed, cache not removed after commit")
+	}
+	if _, err := local2.Get(2); err == nil {
+		t.Error("test UnitOfWork failed, cache not removed after commit")
+	}
+
+	// Rollback 丢弃缓存失效
+	e1.Set(&account{UUID: 1, Username: "zero1"}, 1)
+	u = zeroentity.NewUnitOfWork()
+	ctx = zeroentity.ContextWithUnitOfWork(context.Background(), u)
+	e1.UpdateCtx(ctx, &account{UUID: 1, Username: "one"}, 1)
+	u.Rollback()
+	u.Commit(context.Background())
+
+	if _, err := local1.Get(1); err != nil {
+		t.Errorf("test UnitOfWork rollback failed, cache removed: %s", err.Error())
+	}
+}
@@ -0,0 +1,267 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed 实体已关闭
+	ErrClosed = errors.New("entity closed")
+
+	// ErrWriteBehindFull write-behind 等待写入数据库的数量已达到 MaxPending
+	ErrWriteBehindFull = errors.New("write behind queue full")
+)
+
+// WriteMode 更新时的写入模式
+type WriteMode int
+
+const (
+	// WriteModeInvalidate 默认，更新数据库后删除缓存 (cache-aside，见 WithInvalidation)
+	WriteModeInvalidate WriteMode = iota
+
+	// WriteModeThrough 更新数据库后写入缓存，而不是删除缓存
+	WriteModeThrough
+
+	// WriteModeBehind 立即写入缓存，按时间间隔批量写入数据库
+	// 适用于计数器、玩家状态等频繁更新的数据
+	WriteModeBehind
+)
+
+// WriteBehindConfig write-behind 配置
+type WriteBehindConfig struct {
+	// Interval 写入数据库的时间间隔，默认 1 秒
+	Interval time.Duration
+
+	// MaxPending 等待写入数据库的最大数量 (同一主键会合并)，默认 10000
+	// 达到上限时，新主键的 Update 返回 ErrWriteBehindFull，可以调用 Flush 后重试
+	MaxPending int
+
+	// OnError 写入数据库失败时调用，可选
+	// 失败的数据会重新等待下一次写入，除非期间已经有更新的数据、被删除，或者等待写入的数量已达到上限
+	OnError func(id uint64, model interface{}, err error)
+}
+
+// writeBehind 等待写入数据库的数据
+type writeBehind struct {
+	conf WriteBehindConfig
+
+	mu      sync.Mutex
+	pending map[uint64]*pendingWrite
+	// flushing 正在写入数据库的数据，写入完成前依旧可以通过 pendingBytes 读取
+	flushing map[uint64]*pendingWrite
+	closed   bool
+
+	// flushMu 保证同一时间只有一个批次在写入数据库
+	flushMu sync.Mutex
+}
+
+type pendingWrite struct {
+	model interface{}
+	bs    []byte
+}
+
+func newWriteBehind(conf WriteBehindConfig) *writeBehind {
+	if conf.Interval <= 0 {
+		conf.Interval = time.Second
+	}
+	if conf.MaxPending <= 0 {
+		conf.MaxPending = 10000
+	}
+
+	return &writeBehind{
+		conf:    conf,
+		pending: make(map[uint64]*pendingWrite),
+	}
+}
+
+// WithWriteThrough 更新数据库后写入缓存
+func (e *entity) WithWriteThrough() Entity {
+	e.writeMode = WriteModeThrough
+	return e
+}
+
+// WithWriteBehind 立即写入缓存，按时间间隔批量写入数据库
+//
+// 调用 Update 后不要再修改 model，写入数据库前一直持有 model
+// 进程退出前需要调用 Close，否则未写入数据库的数据会丢失
+func (e *entity) WithWriteBehind(conf WriteBehindConfig) Entity {
+	e.writeMode = WriteModeBehind
+	e.wb = newWriteBehind(conf)
+	return e
+}
+
+// Flush 立即将 write-behind 中等待的数据写入数据库
+func (e *entity) Flush(ctx context.Context) error {
+	if e.wb == nil {
+		return nil
+	}
+
+	e.wb.flushMu.Lock()
+	defer e.wb.flushMu.Unlock()
+
+	e.wb.mu.Lock()
+	pending := e.wb.pending
+	e.wb.pending = make(map[uint64]*pendingWrite, len(pending))
+	e.wb.flushing = pending
+
+	ids := make([]uint64, 0, len(pending))
+	writes := make([]*pendingWrite, 0, len(pending))
+	for id, p := range pending {
+		ids = append(ids, id)
+		writes = append(writes, p)
+	}
+	e.wb.mu.Unlock()
+
+	var firstErr error
+	for idx, id := range ids {
+		p := writes[idx]
+
+		// 写入前已被删除
+		e.wb.mu.Lock()
+		dropped := e.wb.flushing[id] != p
+		e.wb.mu.Unlock()
+		if dropped {
+			continue
+		}
+
+		err := e.writeToDB(ctx, p.model, id)
+
+		e.wb.mu.Lock()
+		// 写入失败，没有更新的数据，也没有被删除，且未达到上限时，重新等待写入
+		if err != nil && e.wb.flushing[id] == p && e.wb.pending[id] == nil &&
+			len(e.wb.pending) < e.wb.conf.MaxPending {
+			e.wb.pending[id] = p
+		}
+		delete(e.wb.flushing, id)
+		e.wb.mu.Unlock()
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if e.wb.conf.OnError != nil {
+				e.wb.conf.OnError(id, p.model, err)
+			}
+		}
+	}
+
+	e.wb.mu.Lock()
+	e.wb.flushing = nil
+	e.wb.mu.Unlock()
+
+	return firstErr
+}
+
+// Close 写入 write-behind 中等待的数据，停止定时器
+func (e *entity) Close() error {
+	var err error
+
+	if e.wb != nil {
+		e.wb.mu.Lock()
+		e.wb.closed = true
+		e.wb.mu.Unlock()
+
+		err = e.Flush(context.Background())
+	}
+
+	// 立即执行等待中的延迟删除
+	e.invalidation.Close()
+
+	e.twp.Close()
+
+	return err
+}
+
+// updateBehind 写入缓存，等待写入数据库
+func (e *entity) updateBehind(ctx context.Context, model interface{}, id uint64) error {
+	bs, err := e.marshal(model)
+	if err != nil {
+		return err
+	}
+
+	e.wb.mu.Lock()
+	if e.wb.closed {
+		e.wb.mu.Unlock()
+		return ErrClosed
+	}
+	if _, ok := e.wb.pending[id]; !ok && len(e.wb.pending) >= e.wb.conf.MaxPending {
+		e.wb.mu.Unlock()
+		return ErrWriteBehindFull
+	}
+	e.wb.pending[id] = &pendingWrite{model: model, bs: bs}
+	e.wb.mu.Unlock()
+
+	e.announceExist(ctx, id)
+
+	e.setCache(ctx, id, bs)
+	e.publishInvalidation(ctx, id)
+
+	return nil
+}
+
+// writeToDB 写入数据库，并调用自定义更新函数，写入成功后标记 id 存在
+func (e *entity) writeToDB(ctx context.Context, model interface{}, id uint64) error {
+	if e.writeDB != nil {
+		if err := e.writeDB.UpdateCtx(ctx, model); err != nil {
+			e.logger.Errorf("failed to update in db, id: %d, err: %s", id, err.Error())
+			return err
+		}
+	}
+	e.announceExist(ctx, id)
+
+	if e.update != nil {
+		if err := e.update(model, id); err != nil {
+			e.logger.Errorf("failed to update in e.update, id: %d, err: %s", id, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// pendingBytes 获取等待写入数据库的数据，缓存被淘汰时依旧可以读取到最新的数据
+func (e *entity) pendingBytes(id uint64) ([]byte, bool) {
+	if e.wb == nil {
+		return nil, false
+	}
+
+	e.wb.mu.Lock()
+	defer e.wb.mu.Unlock()
+
+	p, ok := e.wb.pending[id]
+	if !ok {
+		p, ok = e.wb.flushing[id]
+	}
+	if !ok {
+		return nil, false
+	}
+	return p.bs, true
+}
+
+// dropPending 删除数据时，丢弃等待写入数据库的数据，防止删除后又被写入
+//
+// 数据正在写入数据库时，等待本批次写入完成，保证删除在写入之后执行
+func (e *entity) dropPending(ids ...uint64) {
+	if e.wb == nil {
+		return
+	}
+
+	flushing := false
+
+	e.wb.mu.Lock()
+	for _, id := range ids {
+		delete(e.wb.pending, id)
+		if _, ok := e.wb.flushing[id]; ok {
+			delete(e.wb.flushing, id)
+			flushing = true
+		}
+	}
+	e.wb.mu.Unlock()
+
+	if flushing {
+		e.wb.flushMu.Lock()
+		e.wb.flushMu.Unlock()
+	}
+}
@@ -0,0 +1,210 @@
+package entity_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	zerocmsgpack "github.com/zerogo-hub/zero-helper/codec/msgpack"
+	zeroentity "github.com/zerogo-hub/zero-helper/entity"
+	zeroentitycache "github.com/zerogo-hub/zero-helper/entity/cache"
+	"github.com/zerogo-hub/zero-helper/entity/entitytest"
+	zerologger "github.com/zerogo-hub/zero-helper/logger"
+)
+
+type writeRecorder struct {
+	mu     sync.Mutex
+	writes map[uint64][]string
+}
+
+func (r *writeRecorder) handler(out interface{}, ids ...uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes[ids[0]] = append(r.writes[ids[0]], out.(*account).Username)
+	return nil
+}
+
+func (r *writeRecorder) get(id uint64) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.writes[id]
+}
+
+func TestWriteBehind(t *testing.T) {
+	recorder := &writeRecorder{writes: make(map[uint64][]string)}
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(zeroentitycache.NewBigCache(time.Minute))
+	e.WithCustomUpdateHandler(recorder.handler)
+	e.WithWriteBehind(zeroentity.WriteBehindConfig{Interval: time.Hour, MaxPending: 2})
+	e.Build()
+
+	typed := zeroentity.NewTyped[account](e)
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := typed.Update(&account{UUID: 1, Username: name}, 1); err != nil {
+			t.Fatalf("test Update failed: %s", err.Error())
+		}
+	}
+
+	if writes := recorder.get(1); len(writes) != 0 {
+		t.Errorf("test write behind failed, writes: %v", writes)
+	}
+
+	a, err := typed.Get(1)
+	if err != nil || a.Username != "c" {
+		t.Errorf("test Get failed, account: %v, err: %v", a, err)
+	}
+
+	// 达到 MaxPending 时拒绝新的主键，已等待的主键依旧可以更新
+	if err := typed.Update(&account{UUID: 2, Username: "d"}, 2); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if err := typed.Update(&account{UUID: 3, Username: "e"}, 3); err != zeroentity.ErrWriteBehindFull {
+		t.Errorf("test Update on full failed, err: %v", err)
+	}
+	if err := typed.Update(&account{UUID: 2, Username: "d"}, 2); err != nil {
+		t.Fatalf("test Update on full with pending id failed: %s", err.Error())
+	}
+	if writes := recorder.get(1); len(writes) != 0 {
+		t.Errorf("test Update on full failed, writes: %v", writes)
+	}
+
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatalf("test Flush failed: %s", err.Error())
+	}
+	if writes := recorder.get(1); len(writes) != 1 || writes[0] != "c" {
+		t.Errorf("test Flush failed, writes: %v", writes)
+	}
+
+	if err := typed.Update(&account{UUID: 3, Username: "e"}, 3); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("test Close failed: %s", err.Error())
+	}
+	if writes := recorder.get(3); len(writes) != 1 || writes[0] != "e" {
+		t.Errorf("test flush on close failed, writes: %v", writes)
+	}
+
+	if err := typed.Update(&account{UUID: 3, Username: "f"}, 3); err != zeroentity.ErrClosed {
+		t.Errorf("test Update after close failed, err: %v", err)
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	db := entitytest.NewDB(func(in interface{}) uint64 { return in.(*entitytest.Row).ID })
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithWriteDB(db)
+	e.WithWriteBehind(zeroentity.WriteBehindConfig{Interval: time.Hour})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[entitytest.Row](e)
+	ctx := context.Background()
+	errDown := errors.New("db down")
+
+	// 写入失败时重新等待写入
+	typed.Update(&entitytest.Row{ID: 1, Name: "a"}, 1)
+	db.FailWith(errDown, 1)
+	if err := e.Flush(ctx); err != errDown {
+		t.Errorf("test Flush failed, err: %v", err)
+	}
+	if err := e.Flush(ctx); err != nil {
+		t.Errorf("test Flush retry failed, err: %v", err)
+	}
+	if row, ok := db.Row(1); !ok || row.(entitytest.Row).Name != "a" {
+		t.Errorf("test Flush retry failed, row: %v", row)
+	}
+
+	// 写入期间依旧可以读取，失败后不会覆盖更新的数据
+	typed.Update(&entitytest.Row{ID: 2, Name: "b"}, 2)
+	db.SetLatency(200 * time.Millisecond)
+	db.FailWith(errDown, 1)
+
+	done := make(chan error, 1)
+	go func() { done <- e.Flush(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+
+	if row, err := typed.Get(2); err != nil || row.Name != "b" {
+		t.Errorf("test Get while flushing failed, row: %v, err: %v", row, err)
+	}
+	typed.Update(&entitytest.Row{ID: 2, Name: "c"}, 2)
+
+	if err := <-done; err != errDown {
+		t.Errorf("test Flush failed, err: %v", err)
+	}
+	db.Reset()
+	if err := e.Flush(ctx); err != nil {
+		t.Errorf("test Flush retry failed, err: %v", err)
+	}
+	if row, ok := db.Row(2); !ok || row.(entitytest.Row).Name != "c" {
+		t.Errorf("test Flush superseded failed, row: %v", row)
+	}
+}
+
+func TestWriteBehindDeleteWhileFlushing(t *testing.T) {
+	db := entitytest.NewDB(func(in interface{}) uint64 { return in.(*entitytest.Row).ID })
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithWriteDB(db)
+	e.WithWriteBehind(zeroentity.WriteBehindConfig{Interval: time.Hour})
+	e.Build()
+	defer e.Close()
+
+	typed := zeroentity.NewTyped[entitytest.Row](e)
+	ctx := context.Background()
+
+	typed.Update(&entitytest.Row{ID: 1, Name: "a"}, 1)
+	db.SetLatency(100 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- e.Flush(ctx) }()
+	time.Sleep(30 * time.Millisecond)
+
+	// 删除等待正在执行的写入完成，写入不会在删除之后覆盖
+	db.SetLatency(0)
+	if err := typed.Delete(1); err != nil {
+		t.Fatalf("test Delete while flushing failed: %s", err.Error())
+	}
+	if err := <-done; err != nil {
+		t.Errorf("test Flush failed, err: %v", err)
+	}
+	if row, ok := db.Row(1); ok {
+		t.Errorf("test Delete while flushing failed, row: %v", row)
+	}
+}
+
+func TestWriteThrough(t *testing.T) {
+	recorder := &writeRecorder{writes: make(map[uint64][]string)}
+	local := zeroentitycache.NewBigCache(time.Minute)
+
+	e := zeroentity.New(nil, zerologger.NewSampleLogger(), zerocmsgpack.New())
+	e.WithLocalCache(local)
+	e.WithCustomUpdateHandler(recorder.handler)
+	e.WithWriteThrough()
+	e.Build()
+	defer e.Close()
+
+	if err := e.Update(&account{UUID: 1, Username: "a"}, 1); err != nil {
+		t.Fatalf("test Update failed: %s", err.Error())
+	}
+
+	bs, err := local.Get(1)
+	if err != nil {
+		t.Fatalf("test write through failed: %s", err.Error())
+	}
+
+	var a account
+	if err := e.Unmarshal(bs, &a); err != nil || a.Username != "a" {
+		t.Errorf("test write through failed, account: %v, err: %v", a, err)
+	}
+	if writes := recorder.get(1); len(writes) != 1 {
+		t.Errorf("test write through failed, writes: %v", writes)
+	}
+}
@@ -0,0 +1,622 @@
+// Package cachetest 进程内的 RESP 服务器，用于测试依赖 redis 的代码
+//
+// 实现了测试需要的部分命令，包括事务 (MULTI/EXEC/WATCH)、过期时间 (SET EX/PX/NX、PEXPIRE) 与 Pub/Sub，
+// 其它命令可以通过 SetHandler 自定义，使用示例:
+//
+//	s := cachetest.NewServer(t)
+//	c := zerocache.NewCache(zerocache.WithHost(s.Host()), zerocache.WithPort(s.Port()))
+package cachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Status 简单字符串回复，如 +OK
+type Status string
+
+// Error 错误回复，如 -MOVED 3999 127.0.0.1:6381
+type Error string
+
+// Handler 自定义命令，handled 为 true 时使用其回复，否则按照默认逻辑处理
+type Handler func(conn *Conn, cmd string, args []string) (reply interface{}, handled bool)
+
+// Conn 每个连接的状态
+type Conn struct {
+	// Asking 上一条命令为 ASKING
+	Asking bool
+
+	// 事务状态，watched 为 WATCH 时键的版本
+	multi   bool
+	aborted bool
+	queued  [][]string
+	watched map[string]int
+
+	// channels 已订阅的频道
+	channels map[string]bool
+
+	nc net.Conn
+	// wmu 保护 w，PUBLISH 会从其它连接写入订阅消息
+	wmu sync.Mutex
+	w   *bufio.Writer
+}
+
+// Server 进程内的 RESP 服务器
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	data     map[string]string
+	expireAt map[string]time.Time
+	versions map[string]int
+	handler  Handler
+	conns    map[*Conn]bool
+	// flushes 回复的批次数量，即网络往返次数
+	flushes int
+}
+
+// NewServer 创建并启动服务器，测试结束时关闭
+func NewServer(t testing.TB) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %s", err.Error())
+	}
+
+	s := &Server{
+		ln:       ln,
+		data:     make(map[string]string),
+		expireAt: make(map[string]time.Time),
+		versions: make(map[string]int),
+		conns:    make(map[*Conn]bool),
+	}
+	go s.serve()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Addr 监听地址，如 127.0.0.1:6379
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Host 监听的主机
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+// Port 监听的端口
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// SetHandler 设置自定义命令，nil 表示取消
+func (s *Server) SetHandler(handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handler = handler
+}
+
+// Get 直接读取键的值，不经过命令
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key)
+}
+
+// Set 直接设置键的值，不经过命令，会使 WATCH 该键的事务失败
+func (s *Server) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(key, value, 0)
+}
+
+// Do 直接执行命令，不经过网络，可以在 Handler 中用于实现脚本等命令
+func (s *Server) Do(command ...string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(command)
+}
+
+// RoundTrips 回复的批次数量，即网络往返次数
+func (s *Server) RoundTrips() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flushes
+}
+
+// Clients 当前的连接数量
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// Subscribers 订阅了频道 channel 的连接数量
+func (s *Server) Subscribers(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for conn := range s.conns {
+		if conn.channels[channel] {
+			n++
+		}
+	}
+	return n
+}
+
+// Close 停止监听并断开所有连接，用于模拟服务不可用
+func (s *Server) Close() {
+	s.ln.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.nc.Close()
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(nc)
+	}
+}
+
+func (s *Server) handle(nc net.Conn) {
+	conn := &Conn{nc: nc, w: bufio.NewWriter(nc)}
+
+	s.mu.Lock()
+	s.conns[conn] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		nc.Close()
+	}()
+
+	r := bufio.NewReader(nc)
+	for {
+		command, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		reply := s.exec(conn, command)
+
+		conn.wmu.Lock()
+		if replies, ok := reply.(pushReplies); ok {
+			for _, reply := range replies {
+				writeReply(conn.w, reply)
+			}
+		} else {
+			writeReply(conn.w, reply)
+		}
+
+		// 管道中的命令全部读取后再刷新
+		if r.Buffered() == 0 {
+			s.mu.Lock()
+			s.flushes++
+			s.mu.Unlock()
+
+			err = conn.w.Flush()
+		}
+		conn.wmu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pushReplies 一条命令产生多个回复，如订阅多个频道
+type pushReplies []interface{}
+
+func (s *Server) exec(conn *Conn, command []string) interface{} {
+	cmd, args := strings.ToUpper(command[0]), command[1:]
+
+	s.mu.Lock()
+	handler := s.handler
+	s.mu.Unlock()
+
+	if cmd == "ASKING" {
+		conn.Asking = true
+		return Status("OK")
+	}
+
+	if handler != nil {
+		reply, handled := handler(conn, cmd, args)
+		conn.Asking = false
+		if handled {
+			return reply
+		}
+	}
+	conn.Asking = false
+
+	switch cmd {
+	case "MULTI":
+		conn.multi, conn.aborted, conn.queued = true, false, nil
+		return Status("OK")
+	case "DISCARD":
+		conn.multi, conn.queued, conn.watched = false, nil, nil
+		return Status("OK")
+	case "WATCH":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if conn.watched == nil {
+			conn.watched = make(map[string]int)
+		}
+		for _, key := range args {
+			conn.watched[key] = s.versions[key]
+		}
+		return Status("OK")
+	case "UNWATCH":
+		conn.watched = nil
+		return Status("OK")
+	case "EXEC":
+		return s.execMulti(conn)
+	case "SUBSCRIBE", "UNSUBSCRIBE":
+		return s.subscribe(conn, cmd, args)
+	case "PING":
+		// 订阅状态下的 PING 回复为数组
+		if len(conn.channels) > 0 {
+			message := ""
+			if len(args) > 0 {
+				message = args[0]
+			}
+			return []interface{}{"pong", message}
+		}
+	}
+
+	if conn.multi {
+		if !commands[cmd] {
+			conn.aborted = true
+			return Error("ERR unknown command '" + command[0] + "'")
+		}
+		conn.queued = append(conn.queued, command)
+		return Status("QUEUED")
+	}
+
+	if cmd == "PUBLISH" {
+		return s.publish(args[0], args[1])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(command)
+}
+
+// commands 默认逻辑支持的命令
+var commands = map[string]bool{
+	"PING": true, "ROLE": true, "GET": true, "SET": true, "MSET": true,
+	"MGET": true, "DEL": true, "EXISTS": true, "INCR": true, "PEXPIRE": true,
+	"PTTL": true,
+}
+
+// execMulti 被监视的键版本变化时回复 nil，否则依次执行队列中的命令
+func (s *Server) execMulti(conn *Conn) interface{} {
+	defer func() {
+		conn.multi, conn.aborted, conn.queued, conn.watched = false, false, nil, nil
+	}()
+
+	if !conn.multi {
+		return Error("ERR EXEC without MULTI")
+	}
+	if conn.aborted {
+		return Error("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, version := range conn.watched {
+		if s.versions[key] != version {
+			return []interface{}(nil)
+		}
+	}
+
+	replies := make([]interface{}, 0, len(conn.queued))
+	for _, command := range conn.queued {
+		replies = append(replies, s.apply(command))
+	}
+	return replies
+}
+
+// subscribe 订阅或者解除订阅，每个频道回复一次，UNSUBSCRIBE 不带参数时解除所有订阅
+func (s *Server) subscribe(conn *Conn, cmd string, channels []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn.channels == nil {
+		conn.channels = make(map[string]bool)
+	}
+
+	kind := strings.ToLower(cmd)
+	if cmd == "UNSUBSCRIBE" && len(channels) == 0 {
+		for channel := range conn.channels {
+			channels = append(channels, channel)
+		}
+		if len(channels) == 0 {
+			return pushReplies{[]interface{}{kind, nil, int64(0)}}
+		}
+	}
+
+	replies := make(pushReplies, 0, len(channels))
+	for _, channel := range channels {
+		if cmd == "SUBSCRIBE" {
+			conn.channels[channel] = true
+		} else {
+			delete(conn.channels, channel)
+		}
+		replies = append(replies, []interface{}{kind, channel, int64(len(conn.channels))})
+	}
+	return replies
+}
+
+// publish 将消息写入所有订阅了 channel 的连接，返回接收者数量
+func (s *Server) publish(channel, message string) interface{} {
+	s.mu.Lock()
+	var receivers []*Conn
+	for conn := range s.conns {
+		if conn.channels[channel] {
+			receivers = append(receivers, conn)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, conn := range receivers {
+		conn.wmu.Lock()
+		writeReply(conn.w, []interface{}{"message", channel, message})
+		conn.w.Flush()
+		conn.wmu.Unlock()
+	}
+
+	return int64(len(receivers))
+}
+
+// get 读取未过期的键，调用时需要持有 mu
+func (s *Server) get(key string) (string, bool) {
+	if at, ok := s.expireAt[key]; ok && !time.Now().Before(at) {
+		s.remove(key)
+	}
+
+	v, ok := s.data[key]
+	return v, ok
+}
+
+// put 设置键的值，ttl 为 0 表示永不过期，调用时需要持有 mu
+func (s *Server) put(key, value string, ttl time.Duration) {
+	s.data[key] = value
+	s.versions[key]++
+
+	if ttl > 0 {
+		s.expireAt[key] = time.Now().Add(ttl)
+	} else {
+		delete(s.expireAt, key)
+	}
+}
+
+// remove 删除键，调用时需要持有 mu
+func (s *Server) remove(key string) {
+	delete(s.data, key)
+	delete(s.expireAt, key)
+	s.versions[key]++
+}
+
+// apply 执行命令，调用时需要持有 mu
+func (s *Server) apply(command []string) interface{} {
+	cmd, args := strings.ToUpper(command[0]), command[1:]
+
+	switch cmd {
+	case "PING":
+		return Status("PONG")
+	case "ROLE":
+		return []interface{}{"master", int64(0), []interface{}{}}
+	case "GET":
+		if v, ok := s.get(args[0]); ok {
+			return v
+		}
+		return nil
+	case "SET":
+		return s.set(args)
+	case "MSET":
+		for i := 0; i+1 < len(args); i += 2 {
+			s.put(args[i], args[i+1], 0)
+		}
+		return Status("OK")
+	case "MGET":
+		values := make([]interface{}, 0, len(args))
+		for _, key := range args {
+			if v, ok := s.get(key); ok {
+				values = append(values, v)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		return values
+	case "DEL", "EXISTS":
+		n := int64(0)
+		for _, key := range args {
+			if _, ok := s.get(key); ok {
+				n++
+				if cmd == "DEL" {
+					s.remove(key)
+				}
+			}
+		}
+		return n
+	case "INCR":
+		v, _ := s.get(args[0])
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil && v != "" {
+			return Error("ERR value is not an integer or out of range")
+		}
+		n++
+		s.data[args[0]] = strconv.FormatInt(n, 10)
+		s.versions[args[0]]++
+		return n
+	case "PEXPIRE":
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return Error("ERR value is not an integer or out of range")
+		}
+		if _, ok := s.get(args[0]); !ok {
+			return int64(0)
+		}
+		s.expireAt[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return int64(1)
+	case "PTTL":
+		if _, ok := s.get(args[0]); !ok {
+			return int64(-2)
+		}
+		at, ok := s.expireAt[args[0]]
+		if !ok {
+			return int64(-1)
+		}
+		return int64(time.Until(at) / time.Millisecond)
+	}
+
+	return Error("ERR unknown command '" + command[0] + "'")
+}
+
+// set SET key value [EX seconds | PX milliseconds] [NX | XX]，条件不满足时回复 nil
+func (s *Server) set(args []string) interface{} {
+	if len(args) < 2 {
+		return Error("ERR wrong number of arguments for 'set' command")
+	}
+
+	var (
+		ttl    time.Duration
+		nx, xx bool
+	)
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return Error("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return Error("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(args[i]) == "EX" {
+				unit = time.Second
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return Error("ERR syntax error")
+		}
+	}
+
+	_, exists := s.get(args[0])
+	if (nx && exists) || (xx && !exists) {
+		return nil
+	}
+
+	s.put(args[0], args[1], ttl)
+	return Status("OK")
+}
+
+// readCommand 读取一条命令，格式为 RESP 数组
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("invalid command: %q", line)
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	command := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		command = append(command, string(buf[:size]))
+	}
+
+	return command, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case Error:
+		w.WriteString("-" + string(v) + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []interface{}:
+		if v == nil {
+			w.WriteString("*-1\r\n")
+			return
+		}
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		w.WriteString("-ERR unsupported reply\r\n")
+	}
+}
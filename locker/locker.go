@@ -1 +1,363 @@
+// Package locker 分布式锁
+//
+// 锁保存在 Store 中，可以是单个 redis (NewRedisStore)、多个 redis 组成的 Redlock (NewRedlockStore)
+// 或者进程内的内存 (NewMemoryStore，用于测试)，使用示例:
+//
+//	l := locker.NewRedis(c)
+//	lock, err := l.Lock(ctx, "order:1001", 10*time.Second, locker.WithAutoRenew())
+//	if err != nil {
+//		return err
+//	}
+//	defer lock.Release(context.Background())
 package locker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+)
+
+var (
+	// ErrNotObtained 锁已经被其它持有者获取
+	ErrNotObtained = errors.New("lock not obtained")
+	// ErrNotHeld 锁已经过期或者被其它持有者获取，无法续期或者释放
+	ErrNotHeld = errors.New("lock not held")
+	// ErrInvalidTTL 过期时间必须大于 0
+	ErrInvalidTTL = errors.New("invalid lock ttl")
+)
+
+const (
+	defaultMinBackoff = 10 * time.Millisecond
+	defaultMaxBackoff = 500 * time.Millisecond
+
+	// minRenewInterval 自动续期的最小间隔
+	minRenewInterval = time.Millisecond
+)
+
+// Store 锁的存储
+type Store interface {
+	// Obtain 键不存在时设置为 token，ttl 后过期，返回是否设置成功
+	Obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+
+	// Extend 键的值为 token 时重新设置过期时间为 ttl，返回是否仍然持有
+	Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+
+	// Release 键的值为 token 时删除，返回是否仍然持有
+	Release(ctx context.Context, key, token string) (bool, error)
+}
+
+// Locker 分布式锁
+type Locker interface {
+	// TryLock 尝试获取锁一次，已被持有时返回 ErrNotObtained，ttl <= 0 时返回 ErrInvalidTTL
+	TryLock(ctx context.Context, key string, ttl time.Duration, opts ...Option) (Lock, error)
+
+	// Lock 阻塞直到获取锁，或者 ctx 结束，重试间隔指数递增，见 WithBackoff
+	Lock(ctx context.Context, key string, ttl time.Duration, opts ...Option) (Lock, error)
+}
+
+// Lock 已经获取的锁
+type Lock interface {
+	Key() string
+
+	// Token 本次持有的唯一标识
+	Token() string
+
+	// Extend 续期，重新设置过期时间为 ttl，锁已丢失时返回 ErrNotHeld，ttl <= 0 时返回 ErrInvalidTTL
+	Extend(ctx context.Context, ttl time.Duration) error
+
+	// Release 释放锁，并停止自动续期，锁已丢失时返回 ErrNotHeld
+	Release(ctx context.Context) error
+
+	// Lost 自动续期失败，锁已丢失时关闭，未开启自动续期时永远不会关闭
+	Lost() <-chan struct{}
+}
+
+// Option 获取锁时的选项
+type Option func(*options)
+
+type options struct {
+	// renewInterval 自动续期间隔，0 表示 ttl 的 1/3
+	renewInterval time.Duration
+	autoRenew     bool
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// WithAutoRenew 在后台自动续期，间隔为 ttl 的 1/3，直到 Release
+func WithAutoRenew() Option {
+	return func(o *options) {
+		o.autoRenew = true
+	}
+}
+
+// WithRenewInterval 在后台自动续期，间隔为 interval，需要小于 ttl
+func WithRenewInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.autoRenew = true
+		o.renewInterval = interval
+	}
+}
+
+// WithBackoff Lock 重试间隔，从 min 开始每次翻倍，最大为 max，默认为 10ms 到 500ms
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+type locker struct {
+	store Store
+}
+
+// New 使用 store 创建分布式锁
+func New(store Store) Locker {
+	return &locker{store: store}
+}
+
+// NewRedis 基于单个 redis 的分布式锁
+func NewRedis(c zerocache.Cache) Locker {
+	return New(NewRedisStore(c))
+}
+
+// NewRedlock 基于多个独立 redis 的 Redlock，超过半数节点获取成功才算获取到锁
+func NewRedlock(caches ...zerocache.Cache) Locker {
+	stores := make([]Store, 0, len(caches))
+	for _, c := range caches {
+		stores = append(stores, NewRedisStore(c))
+	}
+	return New(NewRedlockStore(stores...))
+}
+
+// NewMemory 进程内的锁，与分布式锁具有相同的接口，用于测试
+func NewMemory() Locker {
+	return New(NewMemoryStore())
+}
+
+func (lk *locker) TryLock(ctx context.Context, key string, ttl time.Duration, opts ...Option) (Lock, error) {
+	return lk.tryLock(ctx, key, ttl, newOptions(opts))
+}
+
+func (lk *locker) Lock(ctx context.Context, key string, ttl time.Duration, opts ...Option) (Lock, error) {
+	o := newOptions(opts)
+
+	backoff := o.minBackoff
+	for {
+		l, err := lk.tryLock(ctx, key, ttl, o)
+		if err != ErrNotObtained {
+			return l, err
+		}
+
+		// 在 [backoff/2, backoff] 之间随机等待，避免多个等待者同时重试
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > o.maxBackoff {
+			backoff = o.maxBackoff
+		}
+	}
+}
+
+func (lk *locker) tryLock(ctx context.Context, key string, ttl time.Duration, o *options) (Lock, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := lk.store.Obtain(ctx, key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotObtained
+	}
+
+	l := &lock{
+		store: lk.store,
+		key:   key,
+		token: token,
+		ttl:   ttl,
+		lost:  make(chan struct{}),
+	}
+
+	if o.autoRenew {
+		interval := o.renewInterval
+		if interval <= 0 {
+			interval = ttl / 3
+		}
+		if interval < minRenewInterval {
+			interval = minRenewInterval
+		}
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.renew(interval)
+	}
+
+	return l, nil
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{minBackoff: defaultMinBackoff, maxBackoff: defaultMaxBackoff}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.minBackoff <= 0 {
+		o.minBackoff = defaultMinBackoff
+	}
+	if o.maxBackoff < o.minBackoff {
+		o.maxBackoff = o.minBackoff
+	}
+
+	return o
+}
+
+// newToken 生成 16 字节的随机标识
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(half))
+	if err != nil {
+		return d
+	}
+	return time.Duration(half + n.Int64())
+}
+
+type lock struct {
+	store Store
+	key   string
+	token string
+
+	mu       sync.Mutex
+	ttl      time.Duration
+	released bool
+
+	// stop 通知自动续期结束，done 自动续期已经结束
+	stop chan struct{}
+	done chan struct{}
+	lost chan struct{}
+}
+
+func (l *lock) Key() string {
+	return l.key
+}
+
+func (l *lock) Token() string {
+	return l.token
+}
+
+func (l *lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+
+	ok, err := l.store.Extend(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+
+	l.mu.Lock()
+	l.ttl = ttl
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *lock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	released := l.released
+	l.released = true
+	l.mu.Unlock()
+
+	if released {
+		return ErrNotHeld
+	}
+
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+
+	ok, err := l.store.Release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+// renew 每隔 interval 续期一次
+// 续期时发现锁已被其它持有者获取，或者直到过期都无法续期成功时，认为锁已丢失
+func (l *lock) renew(interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	l.mu.Lock()
+	expireAt := time.Now().Add(l.ttl)
+	l.mu.Unlock()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		ttl := l.ttl
+		l.mu.Unlock()
+
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		ok, err := l.store.Extend(ctx, l.key, l.token, ttl)
+		cancel()
+
+		if err == nil && ok {
+			expireAt = start.Add(ttl)
+			continue
+		}
+		if err == nil || time.Now().After(expireAt) {
+			close(l.lost)
+			return
+		}
+	}
+}
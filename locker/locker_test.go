@@ -0,0 +1,208 @@
+package locker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	zerolocker "github.com/zerogo-hub/zero-helper/locker"
+)
+
+func TestTryLock(t *testing.T) {
+	l := zerolocker.NewMemory()
+	ctx := context.Background()
+
+	lock, err := l.TryLock(ctx, "key", time.Second)
+	if err != nil {
+		t.Fatalf("test TryLock failed: %s", err.Error())
+	}
+	if lock.Key() != "key" || len(lock.Token()) != 32 {
+		t.Errorf("test TryLock failed, key: %s, token: %s", lock.Key(), lock.Token())
+	}
+
+	if _, err := l.TryLock(ctx, "key", time.Second); err != zerolocker.ErrNotObtained {
+		t.Errorf("test TryLock on held lock failed, err: %v", err)
+	}
+
+	if err := lock.Extend(ctx, time.Second); err != nil {
+		t.Errorf("test Extend failed: %s", err.Error())
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("test Release failed: %s", err.Error())
+	}
+	if err := lock.Release(ctx); err != zerolocker.ErrNotHeld {
+		t.Errorf("test Release twice failed, err: %v", err)
+	}
+}
+
+func TestInvalidTTL(t *testing.T) {
+	l := zerolocker.NewMemory()
+	ctx := context.Background()
+
+	if _, err := l.TryLock(ctx, "key", 0, zerolocker.WithAutoRenew()); err != zerolocker.ErrInvalidTTL {
+		t.Errorf("test TryLock with zero ttl failed, err: %v", err)
+	}
+
+	// ttl 的 1/3 为 0 时使用最小续期间隔
+	lock, err := l.TryLock(ctx, "key", 2*time.Nanosecond, zerolocker.WithAutoRenew())
+	if err != nil {
+		t.Fatalf("test TryLock with tiny ttl failed: %s", err.Error())
+	}
+	if err := lock.Extend(ctx, -time.Second); err != zerolocker.ErrInvalidTTL {
+		t.Errorf("test Extend with negative ttl failed, err: %v", err)
+	}
+	lock.Release(ctx)
+}
+
+func TestExpired(t *testing.T) {
+	l := zerolocker.NewMemory()
+	ctx := context.Background()
+
+	lock, err := l.TryLock(ctx, "key", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("test TryLock failed: %s", err.Error())
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	// 锁过期后被其它持有者获取，原持有者无法续期与释放
+	other, err := l.TryLock(ctx, "key", time.Second)
+	if err != nil {
+		t.Fatalf("test TryLock after expired failed: %s", err.Error())
+	}
+	if err := lock.Extend(ctx, time.Second); err != zerolocker.ErrNotHeld {
+		t.Errorf("test Extend after expired failed, err: %v", err)
+	}
+	if err := lock.Release(ctx); err != zerolocker.ErrNotHeld {
+		t.Errorf("test Release after expired failed, err: %v", err)
+	}
+	if err := other.Release(ctx); err != nil {
+		t.Errorf("test Release failed: %s", err.Error())
+	}
+}
+
+func TestLock(t *testing.T) {
+	l := zerolocker.NewMemory()
+
+	lock, err := l.TryLock(context.Background(), "key", time.Second)
+	if err != nil {
+		t.Fatalf("test TryLock failed: %s", err.Error())
+	}
+
+	// 持有者释放前，等待者超时
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := l.Lock(ctx, "key", time.Second); err != context.DeadlineExceeded {
+		t.Errorf("test Lock with timeout failed, err: %v", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { lock.Release(context.Background()) })
+
+	start := time.Now()
+	waiter, err := l.Lock(context.Background(), "key", time.Second, zerolocker.WithBackoff(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("test Lock failed: %s", err.Error())
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("test Lock failed, acquired before release")
+	}
+	waiter.Release(context.Background())
+}
+
+func TestAutoRenew(t *testing.T) {
+	l := zerolocker.NewMemory()
+	ctx := context.Background()
+
+	lock, err := l.TryLock(ctx, "key", 30*time.Millisecond, zerolocker.WithAutoRenew())
+	if err != nil {
+		t.Fatalf("test TryLock failed: %s", err.Error())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := l.TryLock(ctx, "key", time.Second); err != zerolocker.ErrNotObtained {
+		t.Errorf("test auto renew failed, err: %v", err)
+	}
+	select {
+	case <-lock.Lost():
+		t.Error("test auto renew failed, lock lost")
+	default:
+	}
+
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("test Release failed: %s", err.Error())
+	}
+	if _, err := l.TryLock(ctx, "key", time.Second); err != nil {
+		t.Errorf("test TryLock after Release failed, err: %v", err)
+	}
+}
+
+func TestAutoRenewLost(t *testing.T) {
+	store := zerolocker.NewMemoryStore()
+	l := zerolocker.New(store)
+	ctx := context.Background()
+
+	lock, err := l.TryLock(ctx, "key", time.Second, zerolocker.WithRenewInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("test TryLock failed: %s", err.Error())
+	}
+
+	// 锁被强制释放后，自动续期发现锁已丢失
+	store.Release(ctx, "key", lock.Token())
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Error("test Lost failed, timeout")
+	}
+}
+
+// downStore 不可用的节点
+type downStore struct{}
+
+var errDown = errors.New("store down")
+
+func (downStore) Obtain(context.Context, string, string, time.Duration) (bool, error) {
+	return false, errDown
+}
+
+func (downStore) Extend(context.Context, string, string, time.Duration) (bool, error) {
+	return false, errDown
+}
+
+func (downStore) Release(context.Context, string, string) (bool, error) {
+	return false, errDown
+}
+
+func TestRedlock(t *testing.T) {
+	ctx := context.Background()
+	a, b := zerolocker.NewMemoryStore(), zerolocker.NewMemoryStore()
+
+	// 3 个节点中 1 个不可用，仍然可以获取锁
+	l := zerolocker.New(zerolocker.NewRedlockStore(a, b, downStore{}))
+	lock, err := l.TryLock(ctx, "key", time.Second)
+	if err != nil {
+		t.Fatalf("test Redlock TryLock failed: %s", err.Error())
+	}
+	if _, err := l.TryLock(ctx, "key", time.Second); err != zerolocker.ErrNotObtained {
+		t.Errorf("test Redlock TryLock on held lock failed, err: %v", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("test Redlock Release failed: %s", err.Error())
+	}
+
+	// 只有少数节点获取成功时，释放已经获取的节点
+	if ok, _ := a.Obtain(ctx, "key", "other", time.Second); !ok {
+		t.Fatal("test Obtain failed")
+	}
+	if _, err := l.TryLock(ctx, "key", time.Second); err != zerolocker.ErrNotObtained {
+		t.Errorf("test Redlock without quorum failed, err: %v", err)
+	}
+	if ok, _ := b.Obtain(ctx, "key", "other", time.Second); !ok {
+		t.Error("test Redlock failed, minority lock not released")
+	}
+
+	// 多数节点不可用时返回错误
+	down := zerolocker.New(zerolocker.NewRedlockStore(a, downStore{}, downStore{}))
+	if _, err := down.TryLock(ctx, "down", time.Second); err != errDown {
+		t.Errorf("test Redlock with nodes down failed, err: %v", err)
+	}
+}
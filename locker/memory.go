@@ -0,0 +1,84 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	token    string
+	expireAt time.Time
+}
+
+// memoryStore 进程内的存储，过期的键在访问时删除
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStore 进程内的存储，用于测试或者单机
+func NewMemoryStore() Store {
+	return &memoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *memoryStore) Obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.get(key); ok {
+		return false, nil
+	}
+
+	s.entries[key] = memoryEntry{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.get(key); !ok || e.token != token {
+		return false, nil
+	}
+
+	s.entries[key] = memoryEntry{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key, token string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.get(key); !ok || e.token != token {
+		return false, nil
+	}
+
+	delete(s.entries, key)
+	return true, nil
+}
+
+// get 获取未过期的键，调用时需要持有 mu
+func (s *memoryStore) get(key string) (memoryEntry, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return e, false
+	}
+	if time.Now().After(e.expireAt) {
+		delete(s.entries, key)
+		return e, false
+	}
+	return e, true
+}
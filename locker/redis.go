@@ -0,0 +1,59 @@
+package locker
+
+import (
+	"context"
+	"time"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+)
+
+const (
+	// extendScript 值为 token 时重新设置过期时间
+	extendScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+
+	// releaseScript 值为 token 时删除，避免删除其它持有者的锁
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+type redisStore struct {
+	c zerocache.Cache
+}
+
+// NewRedisStore 基于单个 redis 的存储
+//
+// 获取: SET key token NX PX ttl，续期与释放通过脚本比较 token
+func NewRedisStore(c zerocache.Cache) Store {
+	return &redisStore{c: c}
+}
+
+func (s *redisStore) Obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	c := s.c.WithContext(ctx)
+
+	_, err := c.String(c.DO("SET", key, token, "NX", "PX", milliseconds(ttl)))
+	if err == zerocache.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *redisStore) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	c := s.c.WithContext(ctx)
+
+	n, err := c.Int(c.Eval(extendScript, 1, key, token, milliseconds(ttl)))
+	return n == 1, err
+}
+
+func (s *redisStore) Release(ctx context.Context, key, token string) (bool, error) {
+	c := s.c.WithContext(ctx)
+
+	n, err := c.Int(c.Eval(releaseScript, 1, key, token))
+	return n == 1, err
+}
+
+// milliseconds 转为毫秒，最小为 1
+func milliseconds(ttl time.Duration) int64 {
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
@@ -0,0 +1,76 @@
+package locker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zerocache "github.com/zerogo-hub/zero-helper/cache"
+	"github.com/zerogo-hub/zero-helper/internal/cachetest"
+	zerolocker "github.com/zerogo-hub/zero-helper/locker"
+)
+
+// openRedis 启动 fake redis，EVAL 按照脚本是否包含 PEXPIRE 区分续期与释放
+func openRedis(t *testing.T) (*cachetest.Server, zerocache.Cache) {
+	s := cachetest.NewServer(t)
+	s.SetHandler(func(conn *cachetest.Conn, cmd string, args []string) (interface{}, bool) {
+		if cmd != "EVAL" {
+			return nil, false
+		}
+
+		key, token := args[2], args[3]
+		if v, ok := s.Get(key); !ok || v != token {
+			return int64(0), true
+		}
+		if len(args) > 4 {
+			return s.Do("PEXPIRE", key, args[4]), true
+		}
+		return s.Do("DEL", key), true
+	})
+
+	c := zerocache.NewCache(zerocache.WithHost(s.Host()), zerocache.WithPort(s.Port()))
+	if err := c.Open(); err != nil {
+		t.Fatalf("test Open failed: %s", err.Error())
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return s, c
+}
+
+func TestRedisStore(t *testing.T) {
+	s, c := openRedis(t)
+	l := zerolocker.NewRedis(c)
+	ctx := context.Background()
+
+	lock, err := l.TryLock(ctx, "order:1001", time.Second)
+	if err != nil {
+		t.Fatalf("test TryLock failed: %s", err.Error())
+	}
+	if v, _ := s.Get("order:1001"); v != lock.Token() {
+		t.Errorf("test TryLock failed, value: %s, token: %s", v, lock.Token())
+	}
+
+	if _, err := l.TryLock(ctx, "order:1001", time.Second); err != zerolocker.ErrNotObtained {
+		t.Errorf("test TryLock on held lock failed, err: %v", err)
+	}
+
+	if err := lock.Extend(ctx, 50*time.Millisecond); err != nil {
+		t.Errorf("test Extend failed: %s", err.Error())
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	// 过期后被其它持有者获取，原持有者无法释放
+	other, err := l.TryLock(ctx, "order:1001", time.Second)
+	if err != nil {
+		t.Fatalf("test TryLock after expired failed: %s", err.Error())
+	}
+	if err := lock.Release(ctx); err != zerolocker.ErrNotHeld {
+		t.Errorf("test Release after expired failed, err: %v", err)
+	}
+	if err := other.Release(ctx); err != nil {
+		t.Errorf("test Release failed: %s", err.Error())
+	}
+	if _, ok := s.Get("order:1001"); ok {
+		t.Error("test Release failed, key still exists")
+	}
+}
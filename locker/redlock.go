@@ -0,0 +1,110 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// clockDriftFactor 时钟漂移系数，有效期需要减去 ttl * clockDriftFactor + 2ms
+	clockDriftFactor = 0.01
+)
+
+// redlock 在多个独立的节点上获取锁，超过半数成功，并且耗时小于有效期时才算获取成功
+type redlock struct {
+	stores []Store
+	quorum int
+}
+
+// NewRedlockStore 由多个独立的存储组成 Redlock
+func NewRedlockStore(stores ...Store) Store {
+	return &redlock{stores: stores, quorum: len(stores)/2 + 1}
+}
+
+func (r *redlock) Obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	n, failed, err := r.each(func(s Store) (bool, error) {
+		return s.Obtain(ctx, key, token, ttl)
+	})
+
+	if n >= r.quorum && r.valid(start, ttl) {
+		return true, nil
+	}
+
+	// 没有获取成功，释放已经获取到的节点
+	r.each(func(s Store) (bool, error) {
+		return s.Release(context.Background(), key, token)
+	})
+
+	return false, r.unreachable(failed, err)
+}
+
+func (r *redlock) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	n, failed, err := r.each(func(s Store) (bool, error) {
+		return s.Extend(ctx, key, token, ttl)
+	})
+
+	if n >= r.quorum && r.valid(start, ttl) {
+		return true, nil
+	}
+	return false, r.unreachable(failed, err)
+}
+
+func (r *redlock) Release(ctx context.Context, key, token string) (bool, error) {
+	n, failed, err := r.each(func(s Store) (bool, error) {
+		return s.Release(ctx, key, token)
+	})
+
+	if n >= r.quorum {
+		return true, nil
+	}
+	return false, r.unreachable(failed, err)
+}
+
+// each 并发地在所有节点上执行 fn，返回成功与出错的节点数量，以及第一个错误
+func (r *redlock) each(fn func(s Store) (bool, error)) (n, failed int, first error) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, s := range r.stores {
+		wg.Add(1)
+		go func(s Store) {
+			defer wg.Done()
+
+			ok, err := fn(s)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				failed++
+				if first == nil {
+					first = err
+				}
+			} else if ok {
+				n++
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	return n, failed, first
+}
+
+// valid 扣除耗时与时钟漂移后，锁是否仍然有效
+func (r *redlock) valid(start time.Time, ttl time.Duration) bool {
+	drift := time.Duration(float64(ttl)*clockDriftFactor) + 2*time.Millisecond
+	return time.Since(start)+drift < ttl
+}
+
+// unreachable 出错的节点过多，无法达到半数时返回错误，否则认为锁被其它持有者获取
+func (r *redlock) unreachable(failed int, err error) error {
+	if len(r.stores)-failed < r.quorum {
+		return err
+	}
+	return nil
+}